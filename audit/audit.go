@@ -0,0 +1,217 @@
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+const (
+	ActionPhotosUpdate    = "photos.update"
+	ActionPhotosUpload    = "photos.upload"
+	ActionPhotoDelete     = "photos.delete"
+	ActionPhotoRestore    = "photos.restore"
+	ActionPhotoPurge      = "photos.purge"
+	ActionAPIKeyCreate    = "apikeys.create"
+	ActionAPIKeyRevoke    = "apikeys.revoke"
+	ActionRequestModerate = "requests.moderate"
+	ActionAlbumCreate     = "albums.create"
+	ActionAlbumUpdate     = "albums.update"
+	ActionAlbumDelete     = "albums.delete"
+
+	dayFormat = "2006-01-02"
+
+	// MaxQueryRange bounds a Query, which reads every entry object in its range.
+	MaxQueryRange = time.Hour * 24 * 92
+)
+
+// Entry records a single mutating call. Changes is keyed by target, then by field.
+type Entry struct {
+	Time    time.Time                    `json:"time"`
+	Actor   string                       `json:"actor"`
+	Action  string                       `json:"action"`
+	Targets []string                     `json:"targets"`
+	Changes map[string]map[string]Change `json:"changes,omitempty"`
+	IP      string                       `json:"ip"`
+}
+
+// NewEntry returns an entry by actor for targets, with the changes from before to after, both keyed
+// by target. A missing before or after value records a creation or removal. If a target's changes
+// can't be diffed, they are left out and the error is returned with the rest of the entry.
+func NewEntry(actor, action string, targets []string, before, after map[string]interface{}) (Entry, error) {
+	sort.Strings(targets)
+	entry := Entry{
+		Time:    time.Now(),
+		Actor:   actor,
+		Action:  action,
+		Targets: targets,
+	}
+	var err error
+	for _, target := range targets {
+		changes, derr := Diff(before[target], after[target])
+		if derr != nil {
+			if err == nil {
+				err = fmt.Errorf("%s: %w", target, derr)
+			}
+			continue
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if entry.Changes == nil {
+			entry.Changes = make(map[string]map[string]Change)
+		}
+		entry.Changes[target] = changes
+	}
+	return entry, err
+}
+
+type Change struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Filter limits Query results. Empty fields match everything.
+type Filter struct {
+	Actor  string
+	Action string
+	From   time.Time
+	To     time.Time
+}
+
+// Log is an append-only audit log in the api bucket. Each entry is its own object under a prefix per
+// UTC day, so concurrent writers never overwrite each other. It exposes no way to modify or remove entries.
+type Log struct {
+	Storage storage.Storage
+}
+
+func NewLog(store storage.Storage) *Log {
+	return &Log{
+		Storage: store,
+	}
+}
+
+func (l *Log) Append(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%d-%s.json", dayPrefix(entry.Time), entry.Time.UnixNano(), hex.EncodeToString(b))
+	return l.Storage.Write(storage.BUCKET_API, key, entry)
+}
+
+// Query returns entries matching filter, oldest first. The range may span at most MaxQueryRange.
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	if filter.From.IsZero() || filter.To.IsZero() || filter.To.Before(filter.From) {
+		return nil, fmt.Errorf("invalid date range")
+	}
+	if filter.To.Sub(filter.From) > MaxQueryRange {
+		return nil, fmt.Errorf("date range longer than %d days", MaxQueryRange/(24*time.Hour))
+	}
+	results := []Entry{}
+	from := filter.From.UTC().Truncate(24 * time.Hour)
+	for day := from; !day.After(filter.To); day = day.AddDate(0, 0, 1) {
+		entries, err := l.readDay(day)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Time.Before(filter.From) || entry.Time.After(filter.To) {
+				continue
+			}
+			if filter.Actor != "" && entry.Actor != filter.Actor {
+				continue
+			}
+			if filter.Action != "" && entry.Action != filter.Action {
+				continue
+			}
+			results = append(results, entry)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Time.Before(results[j].Time)
+	})
+	return results, nil
+}
+
+// readDay returns the entries written on day.
+func (l *Log) readDay(day time.Time) ([]Entry, error) {
+	var entries []Entry
+	keys, err := l.Storage.ListPrefix(storage.BUCKET_API, dayPrefix(day))
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		var entry Entry
+		if err = l.read(key, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (l *Log) read(key string, v interface{}) error {
+	r, err := l.Storage.Get(storage.BUCKET_API, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err = json.NewDecoder(r).Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func dayPrefix(t time.Time) string {
+	return storage.KEY_AUDIT_PREFIX + t.UTC().Format(dayFormat) + "/"
+}
+
+// Diff returns the top-level JSON fields that differ between before and after. Either may be nil.
+func Diff(before, after interface{}) (map[string]Change, error) {
+	b, err := toMap(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toMap(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(map[string]Change)
+	for k, v := range b {
+		if !reflect.DeepEqual(v, a[k]) {
+			changes[k] = Change{Before: v, After: a[k]}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = Change{After: v}
+		}
+	}
+	return changes, nil
+}
+
+func toMap(v interface{}) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	if v == nil {
+		return m, nil
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(j, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
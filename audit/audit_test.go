@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+func TestAppendQuery(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewLog(store)
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	if err = l.Append(Entry{Time: now.Add(-time.Hour), Actor: "c", Action: ActionPhotoDelete}); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry := Entry{Time: now.Add(time.Duration(i) * time.Minute), Actor: "a", Action: ActionPhotosUpdate}
+			if err := l.Append(entry); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err = l.Append(Entry{Time: now.AddDate(0, 0, 1), Actor: "b", Action: ActionPhotosUpdate}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+		err    bool
+	}{
+		{name: "day", filter: Filter{From: now.Add(-12 * time.Hour), To: now.Add(11 * time.Hour)}, want: 11},
+		{name: "two days", filter: Filter{From: now.Add(-12 * time.Hour), To: now.AddDate(0, 0, 2)}, want: 12},
+		{name: "actor", filter: Filter{Actor: "a", From: now.Add(-12 * time.Hour), To: now.AddDate(0, 0, 2)}, want: 10},
+		{name: "action", filter: Filter{Action: ActionPhotoDelete, From: now.Add(-12 * time.Hour), To: now.AddDate(0, 0, 2)}, want: 1},
+		{name: "inverted", filter: Filter{From: now, To: now.Add(-time.Hour)}, err: true},
+		{name: "too long", filter: Filter{From: now.Add(-MaxQueryRange - time.Hour), To: now}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := l.Query(tt.filter)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.want {
+				t.Fatalf("got %d entries, want %d", len(entries), tt.want)
+			}
+			for i := 1; i < len(entries); i++ {
+				if entries[i].Time.Before(entries[i-1].Time) {
+					t.Errorf("entries out of order: %v before %v", entries[i-1].Time, entries[i].Time)
+				}
+			}
+		})
+	}
+}
+
+func TestNewEntry(t *testing.T) {
+	before := map[string]interface{}{
+		"a": map[string]string{"caption": "old", "tag": "live"},
+		"b": map[string]string{"caption": "same"},
+	}
+	after := map[string]interface{}{
+		"a": map[string]string{"caption": "new", "tag": "live"},
+		"b": map[string]string{"caption": "same"},
+		"c": map[string]string{"caption": "created"},
+	}
+	entry, err := NewEntry("me", ActionPhotosUpdate, []string{"c", "b", "a"}, before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entry.Targets, []string{"a", "b", "c"}) {
+		t.Errorf("targets = %v", entry.Targets)
+	}
+	want := map[string]map[string]Change{
+		"a": {"caption": {Before: "old", After: "new"}},
+		"c": {"caption": {After: "created"}},
+	}
+	if !reflect.DeepEqual(entry.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", entry.Changes, want)
+	}
+	if entry.Time.IsZero() || entry.Actor != "me" || entry.Action != ActionPhotosUpdate {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	entry, err = NewEntry("me", ActionPhotosUpdate, []string{"a", "bad"}, before, map[string]interface{}{"a": after["a"], "bad": func() {}})
+	if err == nil {
+		t.Error("expected error diffing an unencodable value")
+	}
+	if _, ok := entry.Changes["a"]; !ok {
+		t.Error("changes of other targets dropped on error")
+	}
+}
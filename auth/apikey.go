@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+const (
+	// APIKeyPrefix marks a bearer token as an API key rather than a GCP access token.
+	APIKeyPrefix = "cea_"
+
+	ScopeAdmin        = "admin"
+	ScopeOwner        = "owner"
+	ScopePhotosRead   = "photos:read"
+	ScopePhotosWrite  = "photos:write"
+	ScopeRequestsRead = "requests:read"
+	ScopeModerate     = "requests:moderate"
+
+	lastUsedInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrAPIKeyExpired = errors.New("api key is expired")
+	ErrAPIKeyRevoked = errors.New("api key is revoked")
+	ErrInvalidScope  = errors.New("invalid scope")
+
+	// APIKeyScopes are the scopes that may be granted to an API key. ScopeAdmin is
+	// reserved for GCP-authenticated users so that keys cannot mint other keys.
+	APIKeyScopes = []string{ScopePhotosRead, ScopePhotosWrite, ScopeRequestsRead, ScopeModerate}
+)
+
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash,omitempty"`
+	Scopes    []string  `json:"scopes"`
+	CreatedBy string    `json:"createdBy"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires,omitempty"`
+	LastUsed  time.Time `json:"lastUsed,omitempty"` // stored separately, see Authorize
+	Revoked   time.Time `json:"revoked,omitempty"`
+}
+
+// APIKeys manages API keys stored as a map of id:APIKey in the api bucket.
+type APIKeys struct {
+	Storage storage.Storage
+	mu      sync.Mutex
+	used    map[string]time.Time // id:last-used time this instance wrote
+}
+
+func NewAPIKeys(store storage.Storage) *APIKeys {
+	return &APIKeys{
+		Storage: store,
+		used:    make(map[string]time.Time),
+	}
+}
+
+// Create generates a new key and returns its plaintext token. The token is not stored and cannot be recovered.
+func (a *APIKeys) Create(name string, scopes []string, expires time.Time, createdBy string) (string, *APIKey, error) {
+	if name == "" {
+		return "", nil, fmt.Errorf("name required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return "", nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", nil, err
+	}
+	key := APIKey{
+		ID:        id,
+		Name:      name,
+		Hash:      hashSecret(secret),
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		Created:   time.Now(),
+		Expires:   expires,
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	keys, err := a.read()
+	if err != nil {
+		return "", nil, err
+	}
+	keys[id] = key
+	if err = a.Storage.Write(storage.BUCKET_API, storage.KEY_API_KEYS, keys); err != nil {
+		return "", nil, err
+	}
+	key.Hash = ""
+	return fmt.Sprintf("%s%s.%s", APIKeyPrefix, id, secret), &key, nil
+}
+
+// List returns all keys, including revoked and expired ones, ordered by creation time. Hashes are omitted.
+func (a *APIKeys) List() ([]APIKey, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	keys, err := a.read()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]APIKey, 0, len(keys))
+	for _, key := range keys {
+		key.Hash = ""
+		var lastUsed time.Time
+		if err = a.readJSON(storage.KEY_LAST_USED+key.ID, &lastUsed); err != nil {
+			return nil, err
+		}
+		if lastUsed.After(key.LastUsed) {
+			key.LastUsed = lastUsed
+		}
+		list = append(list, key)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list, nil
+}
+
+// Revoke marks a key as revoked. Revoked keys are kept so that they remain visible in List.
+func (a *APIKeys) Revoke(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	keys, err := a.read()
+	if err != nil {
+		return err
+	}
+	key, ok := keys[id]
+	if !ok {
+		return ErrInvalidAPIKey
+	}
+	if !key.Revoked.IsZero() {
+		return nil
+	}
+	key.Revoked = time.Now()
+	keys[id] = key
+	return a.Storage.Write(storage.BUCKET_API, storage.KEY_API_KEYS, keys)
+}
+
+// Authorize validates a plaintext token and records its use in an object of its own, so that it
+// never races with Create or Revoke on another instance.
+func (a *APIKeys) Authorize(token string) (*APIKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, APIKeyPrefix), ".")
+	if !ok || id == "" || secret == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	keys, err := a.read()
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashSecret(secret))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if !key.Revoked.IsZero() {
+		return nil, ErrAPIKeyRevoked
+	}
+	if !key.Expires.IsZero() && time.Now().After(key.Expires) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	// only persist last-used periodically so that busy clients don't write on every request
+	if now := time.Now(); now.Sub(a.used[id]) > lastUsedInterval {
+		if err = a.Storage.Write(storage.BUCKET_API, storage.KEY_LAST_USED+id, now); err != nil {
+			return nil, err
+		}
+		a.used[id] = now
+		key.LastUsed = now
+	}
+	key.Hash = ""
+	return &key, nil
+}
+
+func (a *APIKeys) read() (map[string]APIKey, error) {
+	keys := make(map[string]APIKey)
+	if err := a.readJSON(storage.KEY_API_KEYS, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// readJSON decodes the object at key into v, leaving v unchanged if it doesn't exist.
+func (a *APIKeys) readJSON(key string, v interface{}) error {
+	r, err := a.Storage.Get(storage.BUCKET_API, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err = json.NewDecoder(r).Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func validScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+func TestAuthorizeDoesNotRewriteKeys(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// two instances sharing storage
+	a, b := NewAPIKeys(store), NewAPIKeys(store)
+	token, key, err := a.Create("uploader", []string{ScopePhotosWrite}, time.Time{}, "owner@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := readAll(t, store, storage.KEY_API_KEYS)
+	if _, err = a.Authorize(token); err != nil {
+		t.Fatal(err)
+	}
+	if after := readAll(t, store, storage.KEY_API_KEYS); after != before {
+		t.Errorf("Authorize rewrote %s", storage.KEY_API_KEYS)
+	}
+	list, err := b.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].LastUsed.IsZero() {
+		t.Errorf("List() = %+v, want LastUsed set", list)
+	}
+
+	if err = b.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.Authorize(token); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Fatalf("err = %v, want %v", err, ErrAPIKeyRevoked)
+	}
+	if _, err = b.Authorize(token); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("revoked key authorized on another instance: err = %v", err)
+	}
+}
+
+func readAll(t *testing.T, store storage.Storage, key string) string {
+	t.Helper()
+	r, err := store.Get(storage.BUCKET_API, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 )
 
 var ErrTokenExpired = errors.New("token is expired")
@@ -26,45 +25,25 @@ type TokenInfo struct {
 	Scope         string `json:"scope"`
 }
 
-func (g *GCP) Authorize(ctx context.Context, accessToken string) error {
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://www.googleapis.com/oauth2/v1/tokeninfo?access_token=%s", accessToken), nil)
+// Authorize validates a GCP access token and returns its token info.
+func (g *GCP) Authorize(ctx context.Context, accessToken string) (*TokenInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://www.googleapis.com/oauth2/v1/tokeninfo?access_token=%s", accessToken), nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	cli := &http.Client{}
 	resp, err := cli.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	var tok TokenInfo
 	err = json.NewDecoder(resp.Body).Decode(&tok)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if tok.ExpiresIn < 1 {
-		return ErrTokenExpired
+		return nil, ErrTokenExpired
 	}
-	return nil
-}
-
-func (g *GCP) Middleware(next http.HandlerFunc) http.HandlerFunc {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get("Authorization")
-		if token == "" {
-			w.WriteHeader(http.StatusForbidden)
-			w.Write([]byte(`{"message":"missing token"}`))
-			return
-		}
-		token = strings.TrimPrefix(token, "Bearer ")
-
-		if err := g.Authorize(r.Context(), token); err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"message":"unauthorized"}`))
-			return
-		}
-
-		ctx := context.WithValue(r.Context(), "gcp_access_token", token)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	return &tok, nil
 }
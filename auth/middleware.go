@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+)
+
+type contextKey string
+
+const identityKey contextKey = "identity"
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	Email  string   `json:"email,omitempty"`
+	APIKey string   `json:"apiKey,omitempty"` // id of the API key, if authenticated by key
+	Name   string   `json:"name,omitempty"`
+	Scopes []string `json:"scopes"`
+}
+
+// HasScope reports whether the identity was granted scope. ScopeAdmin implies every scope except ScopeOwner.
+func (i *Identity) HasScope(scope string) bool {
+	if scope == "" {
+		return true
+	}
+	for _, s := range i.Scopes {
+		if s == scope || (s == ScopeAdmin && scope != ScopeOwner) {
+			return true
+		}
+	}
+	return false
+}
+
+// Actor returns a human-readable name for the identity.
+func (i *Identity) Actor() string {
+	if i.Email != "" {
+		return i.Email
+	}
+	return "apikey:" + i.Name
+}
+
+// IdentityFromContext returns the identity set by Authenticator.Middleware, or nil.
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey).(*Identity)
+	return identity
+}
+
+// TokenAuthorizer validates an access token and returns its token info.
+type TokenAuthorizer interface {
+	Authorize(ctx context.Context, accessToken string) (*TokenInfo, error)
+}
+
+// Authenticator accepts either GCP access tokens or API keys in the Authorization header.
+type Authenticator struct {
+	GCP     TokenAuthorizer
+	APIKeys *APIKeys
+}
+
+func NewAuthenticator(apiKeys *APIKeys) *Authenticator {
+	return &Authenticator{
+		GCP:     &GCP{},
+		APIKeys: apiKeys,
+	}
+}
+
+// Authenticate identifies the caller. API keys carry the scopes they were created with. Google
+// accounts get ScopeAdmin only if their verified email is listed in ADMIN_EMAILS or OWNER_EMAILS,
+// and ScopeOwner only if it's listed in OWNER_EMAILS; any other account has no scopes.
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if strings.HasPrefix(token, APIKeyPrefix) {
+		key, err := a.APIKeys.Authorize(token)
+		if err != nil {
+			return nil, err
+		}
+		return &Identity{
+			APIKey: key.ID,
+			Name:   key.Name,
+			Scopes: key.Scopes,
+		}, nil
+	}
+	tok, err := a.GCP.Authorize(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []string{}
+	if tok.EmailVerified {
+		owner := listed("OWNER_EMAILS", tok.Email)
+		if owner || listed("ADMIN_EMAILS", tok.Email) {
+			scopes = append(scopes, ScopeAdmin)
+		}
+		if owner {
+			scopes = append(scopes, ScopeOwner)
+		}
+	}
+	return &Identity{
+		Email:  tok.Email,
+		Scopes: scopes,
+	}, nil
+}
+
+// listed reports whether email is in the comma separated list in environment variable env.
+func listed(env, email string) bool {
+	if email == "" {
+		return false
+	}
+	for _, e := range strings.Split(os.Getenv(env), ",") {
+		if strings.EqualFold(strings.TrimSpace(e), email) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware requires an authenticated identity holding scope. An empty scope admits any authenticated identity.
+func (a *Authenticator) Middleware(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"missing token"}`))
+			return
+		}
+
+		identity, err := a.Authenticate(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"unauthorized"}`))
+			return
+		}
+		if !identity.HasScope(scope) {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"insufficient scope"}`))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), identityKey, identity)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+type stubGCP map[string]TokenInfo
+
+func (s stubGCP) Authorize(ctx context.Context, accessToken string) (*TokenInfo, error) {
+	tok, ok := s[accessToken]
+	if !ok {
+		return nil, ErrTokenExpired
+	}
+	return &tok, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Setenv("OWNER_EMAILS", "owner@example.com")
+	t.Setenv("ADMIN_EMAILS", "admin@example.com, Other@Example.com")
+
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKeys := NewAPIKeys(store)
+	token, _, err := apiKeys.Create("uploader", []string{ScopePhotosWrite}, time.Time{}, "owner@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Authenticator{
+		GCP: stubGCP{
+			"owner":      {Email: "owner@example.com", EmailVerified: true, ExpiresIn: 60},
+			"admin":      {Email: "admin@example.com", EmailVerified: true, ExpiresIn: 60},
+			"other":      {Email: "other@example.com", EmailVerified: true, ExpiresIn: 60},
+			"unverified": {Email: "admin@example.com", ExpiresIn: 60},
+			"stranger":   {Email: "stranger@example.com", EmailVerified: true, ExpiresIn: 60},
+		},
+		APIKeys: apiKeys,
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		scopes []string
+		err    bool
+	}{
+		{name: "owner", token: "owner", scopes: []string{ScopeAdmin, ScopeOwner}},
+		{name: "admin", token: "admin", scopes: []string{ScopeAdmin}},
+		{name: "admin case insensitive", token: "other", scopes: []string{ScopeAdmin}},
+		{name: "unverified admin", token: "unverified", scopes: []string{}},
+		{name: "unlisted account", token: "stranger", scopes: []string{}},
+		{name: "invalid token", token: "bogus", err: true},
+		{name: "api key", token: token, scopes: []string{ScopePhotosWrite}},
+		{name: "invalid api key", token: APIKeyPrefix + "0000.0000", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			identity, err := a.Authenticate(r)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", identity)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(identity.Scopes, tt.scopes) {
+				t.Errorf("scopes = %v, want %v", identity.Scopes, tt.scopes)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{scopes: nil, scope: "", want: true},
+		{scopes: nil, scope: ScopePhotosRead, want: false},
+		{scopes: []string{ScopePhotosRead}, scope: ScopePhotosRead, want: true},
+		{scopes: []string{ScopePhotosRead}, scope: ScopePhotosWrite, want: false},
+		{scopes: []string{ScopeAdmin}, scope: ScopeModerate, want: true},
+		{scopes: []string{ScopeAdmin}, scope: ScopeOwner, want: false},
+		{scopes: []string{ScopeAdmin, ScopeOwner}, scope: ScopeOwner, want: true},
+	}
+	for _, tt := range tests {
+		identity := &Identity{Scopes: tt.scopes}
+		if got := identity.HasScope(tt.scope); got != tt.want {
+			t.Errorf("%v.HasScope(%q) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
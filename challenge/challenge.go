@@ -0,0 +1,69 @@
+package challenge
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrChallengeFailed = errors.New("challenge failed")
+	ErrHoneypot        = errors.New("honeypot field is not empty")
+	ErrTooFast         = errors.New("form submitted too quickly")
+	ErrReplayed        = errors.New("challenge already used")
+)
+
+// Challenge is issued to a client before it submits a form.
+type Challenge struct {
+	Token      string `json:"token"`
+	Difficulty int    `json:"difficulty"` // leading zero bits required of sha256(token + ":" + solution)
+}
+
+// Submission is the client's answer to a Challenge, along with form fields used by heuristics.
+type Submission struct {
+	Token    string `json:"token"`
+	Solution string `json:"solution"`
+	Response string `json:"response,omitempty"` // hosted captcha response, if any
+	Honeypot string `json:"-"`
+	RemoteIP string `json:"-"`
+}
+
+// Verifier decides whether a submission came from a human. Implementations may be local
+// (ProofOfWork, Honeypot) or call out to a hosted captcha.
+type Verifier interface {
+	Verify(ctx context.Context, submission Submission) error
+}
+
+// Chain runs each verifier in order and returns the first error.
+type Chain []Verifier
+
+func (c Chain) Verify(ctx context.Context, submission Submission) error {
+	for _, verifier := range c {
+		if err := verifier.Verify(ctx, submission); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Honeypot rejects submissions that filled in a field hidden from humans.
+type Honeypot struct{}
+
+func (Honeypot) Verify(ctx context.Context, submission Submission) error {
+	if submission.Honeypot != "" {
+		return ErrHoneypot
+	}
+	return nil
+}
+
+// Stub stands in for a hosted captcha. It accepts submissions whose Response equals Token,
+// or every submission if Token is empty.
+type Stub struct {
+	Token string
+}
+
+func (s Stub) Verify(ctx context.Context, submission Submission) error {
+	if s.Token != "" && submission.Response != s.Token {
+		return ErrChallengeFailed
+	}
+	return nil
+}
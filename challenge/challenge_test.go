@@ -0,0 +1,50 @@
+package challenge
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStub(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		response string
+		err      error
+	}{
+		{name: "no token accepts anything", token: "", response: "whatever"},
+		{name: "no token accepts no response", token: "", response: ""},
+		{name: "matching response", token: "secret", response: "secret"},
+		{name: "wrong response", token: "secret", response: "guess", err: ErrChallengeFailed},
+		{name: "missing response", token: "secret", response: "", err: ErrChallengeFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Stub{Token: tt.token}.Verify(context.Background(), Submission{Response: tt.response})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	chain := Chain{Honeypot{}, Stub{Token: "secret"}}
+	tests := []struct {
+		name       string
+		submission Submission
+		err        error
+	}{
+		{name: "passes all", submission: Submission{Response: "secret"}},
+		{name: "honeypot first", submission: Submission{Honeypot: "bot", Response: "wrong"}, err: ErrHoneypot},
+		{name: "stub", submission: Submission{Response: "wrong"}, err: ErrChallengeFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := chain.Verify(context.Background(), tt.submission); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
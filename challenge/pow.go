@@ -0,0 +1,119 @@
+package challenge
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+const (
+	defaultDifficulty = 18
+	defaultTTL        = time.Minute * 10
+	defaultMinSubmit  = time.Second * 3
+)
+
+// ProofOfWork issues signed nonces and verifies that the client found a solution
+// whose hash has Difficulty leading zero bits. It also enforces a minimum time between
+// issue and submission.
+//
+// Used nonces are recorded in Storage so a solution can't be replayed on another Lambda instance. The
+// check and the record aren't atomic, so two instances verifying the same solution at the same moment
+// may both accept it; within an instance, verification is serialized.
+type ProofOfWork struct {
+	Secret     []byte
+	Difficulty int
+	TTL        time.Duration
+	MinSubmit  time.Duration
+	Storage    storage.Storage
+
+	mu sync.Mutex
+}
+
+type claims struct {
+	Difficulty int `json:"difficulty"`
+	jwt.RegisteredClaims
+}
+
+func NewProofOfWork(secret string, store storage.Storage) *ProofOfWork {
+	return &ProofOfWork{
+		Secret:     []byte(secret),
+		Difficulty: defaultDifficulty,
+		TTL:        defaultTTL,
+		MinSubmit:  defaultMinSubmit,
+		Storage:    store,
+	}
+}
+
+func (p *ProofOfWork) Issue() (*Challenge, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		Difficulty: p.Difficulty,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(nonce),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(p.TTL)),
+		},
+	}).SignedString(p.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return &Challenge{
+		Token:      token,
+		Difficulty: p.Difficulty,
+	}, nil
+}
+
+func (p *ProofOfWork) Verify(ctx context.Context, submission Submission) error {
+	var c claims
+	_, err := jwt.ParseWithClaims(submission.Token, &c, func(token *jwt.Token) (interface{}, error) {
+		return p.Secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrChallengeFailed, err)
+	}
+	if c.ExpiresAt == nil {
+		return ErrChallengeFailed
+	}
+	if c.IssuedAt == nil || time.Since(c.IssuedAt.Time) < p.MinSubmit {
+		return ErrTooFast
+	}
+	if leadingZeroBits(submission.Token, submission.Solution) < c.Difficulty {
+		return ErrChallengeFailed
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := storage.KEY_NONCE_PREFIX + c.ID
+	used, err := p.Storage.Exists(storage.BUCKET_API, key)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrReplayed
+	}
+	return p.Storage.Write(storage.BUCKET_API, key, c.ExpiresAt.Time)
+}
+
+func leadingZeroBits(token, solution string) int {
+	sum := sha256.Sum256([]byte(token + ":" + solution))
+	var n int
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,76 @@
+package challenge
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+func newProofOfWork(t *testing.T, store storage.Storage) *ProofOfWork {
+	t.Helper()
+	p := NewProofOfWork("secret", store)
+	p.Difficulty = 4
+	p.MinSubmit = 0
+	return p
+}
+
+func solve(t *testing.T, c *Challenge) string {
+	t.Helper()
+	for i := 0; ; i++ {
+		solution := strconv.Itoa(i)
+		if leadingZeroBits(c.Token, solution) >= c.Difficulty {
+			return solution
+		}
+	}
+}
+
+func TestProofOfWork(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProofOfWork(t, store)
+	ctx := context.Background()
+
+	c, err := p.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	solution := solve(t, c)
+	if err = p.Verify(ctx, Submission{Token: c.Token, Solution: solution}); err != nil {
+		t.Fatal(err)
+	}
+	// another instance sharing the same storage
+	other := newProofOfWork(t, store)
+	if err = other.Verify(ctx, Submission{Token: c.Token, Solution: solution}); !errors.Is(err, ErrReplayed) {
+		t.Errorf("replay: err = %v, want %v", err, ErrReplayed)
+	}
+
+	c, err = p.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := "x"
+	for leadingZeroBits(c.Token, wrong) >= c.Difficulty {
+		wrong += "x"
+	}
+	if err = p.Verify(ctx, Submission{Token: c.Token, Solution: wrong}); !errors.Is(err, ErrChallengeFailed) {
+		t.Errorf("wrong solution: err = %v, want %v", err, ErrChallengeFailed)
+	}
+	if err = p.Verify(ctx, Submission{Token: "not a token", Solution: wrong}); !errors.Is(err, ErrChallengeFailed) {
+		t.Errorf("invalid token: err = %v, want %v", err, ErrChallengeFailed)
+	}
+
+	p.MinSubmit = time.Hour
+	c, err = p.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Verify(ctx, Submission{Token: c.Token, Solution: solve(t, c)}); !errors.Is(err, ErrTooFast) {
+		t.Errorf("too fast: err = %v, want %v", err, ErrTooFast)
+	}
+}
@@ -0,0 +1,135 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+	StatusPartial = "partial" // some items failed
+)
+
+// Source is where an item's image comes from: a Google Photos URL or a key in the uploads bucket.
+type Source struct {
+	URL    string `json:"url,omitempty"`
+	Upload string `json:"upload,omitempty"`
+}
+
+// Item is one photo within a job.
+type Item struct {
+	ID       string         `json:"id"`
+	Filename string         `json:"filename"`
+	Source   Source         `json:"source"`
+	Metadata photo.Metadata `json:"metadata"`
+	Status   string         `json:"status"`
+	Error    string         `json:"error,omitempty"`
+	// Duplicates are existing photos that look like this one.
+	Duplicates []string `json:"duplicates,omitempty"`
+}
+
+// Job is a batch of photos to process asynchronously.
+type Job struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	Status  string    `json:"status"`
+	Actor   string    `json:"actor"`
+	IP      string    `json:"ip"`
+	Items   []Item    `json:"items"`
+	// RejectDuplicates fails items that look like an existing photo, rather than just listing
+	// their Duplicates.
+	RejectDuplicates bool `json:"rejectDuplicates,omitempty"`
+}
+
+// Event is the payload sent to the worker.
+type Event struct {
+	JobID string `json:"jobId"`
+}
+
+// Finish sets the job's status from its items' statuses.
+func (j *Job) Finish() {
+	var done, failed int
+	for _, item := range j.Items {
+		switch item.Status {
+		case StatusDone:
+			done++
+		case StatusFailed:
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		j.Status = StatusDone
+	case done == 0:
+		j.Status = StatusFailed
+	default:
+		j.Status = StatusPartial
+	}
+}
+
+// Store persists jobs as one object per job in the api bucket.
+type Store struct {
+	Storage storage.Storage
+}
+
+func NewStore(store storage.Storage) *Store {
+	return &Store{
+		Storage: store,
+	}
+}
+
+func (s *Store) Save(job *Job) error {
+	job.Updated = time.Now()
+	return s.Storage.Write(storage.BUCKET_API, key(job.ID), job)
+}
+
+func (s *Store) Get(id string) (*Job, error) {
+	r, err := s.Storage.Get(storage.BUCKET_API, key(id))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var job Job
+	if err = json.NewDecoder(r).Decode(&job); err != nil {
+		return nil, fmt.Errorf("job %s not found", id)
+	}
+	return &job, nil
+}
+
+// Unfinished returns the ids of items in queued or running jobs that haven't failed. Their objects
+// may be stored before their metadata is.
+func (s *Store) Unfinished() (map[string]struct{}, error) {
+	keys, err := s.Storage.ListPrefix(storage.BUCKET_API, storage.KEY_JOBS_PREFIX)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]struct{})
+	for _, k := range keys {
+		job, err := s.Get(strings.TrimSuffix(strings.TrimPrefix(k, storage.KEY_JOBS_PREFIX), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if job.Status != StatusQueued && job.Status != StatusRunning {
+			continue
+		}
+		for _, item := range job.Items {
+			if item.Status != StatusFailed {
+				ids[item.ID] = struct{}{}
+			}
+		}
+	}
+	return ids, nil
+}
+
+func key(id string) string {
+	return storage.KEY_JOBS_PREFIX + id + ".json"
+}
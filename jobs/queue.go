@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Queue hands a saved job to a worker.
+type Queue interface {
+	Enqueue(id string) error
+}
+
+// RunFunc processes a job.
+type RunFunc func(ctx context.Context, id string) error
+
+// LocalQueue runs jobs one at a time in a background goroutine of the current process.
+type LocalQueue struct {
+	ids chan string
+}
+
+func NewLocalQueue(run RunFunc) *LocalQueue {
+	q := &LocalQueue{
+		ids: make(chan string, 100),
+	}
+	go func() {
+		for id := range q.ids {
+			if err := run(context.Background(), id); err != nil {
+				log.Print("error running job ", id, ": ", err)
+			}
+		}
+	}()
+	return q
+}
+
+func (q *LocalQueue) Enqueue(id string) error {
+	q.ids <- id
+	return nil
+}
+
+// LambdaQueue asynchronously invokes the worker Lambda function with an Event.
+type LambdaQueue struct {
+	Client   *lambda.Lambda
+	Function string
+}
+
+func NewLambdaQueue(profile, function string) (*LambdaQueue, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Profile: profile,
+		Config: aws.Config{
+			Region: aws.String("us-west-1"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &LambdaQueue{
+		Client:   lambda.New(sess),
+		Function: function,
+	}, nil
+}
+
+func (q *LambdaQueue) Enqueue(id string) error {
+	payload, err := json.Marshal(Event{JobID: id})
+	if err != nil {
+		return err
+	}
+	_, err = q.Client.Invoke(&lambda.InvokeInput{
+		FunctionName:   aws.String(q.Function),
+		InvocationType: aws.String(lambda.InvocationTypeEvent),
+		Payload:        payload,
+	})
+	return err
+}
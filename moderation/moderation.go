@@ -0,0 +1,123 @@
+package moderation
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/stinkyfingers/chadedwardsapi/request"
+)
+
+const (
+	defaultMaxName    = 50
+	defaultMaxMessage = 280
+	defaultMaxSong    = 100
+
+	ReasonWord  = "word"
+	ReasonURL   = "url"
+	ReasonPhone = "phone"
+)
+
+var (
+	urlPattern   = regexp.MustCompile(`(?i)(https?://\S+|www\.\S+|\b[a-z0-9-]+\.(com|net|org|io|co|ly|me|info|biz|xyz|gg|app)\b\S*)`)
+	spacePattern = regexp.MustCompile(`\s{2,}`)
+
+	// phone numbers of 10 or more digits: North American with an optional leading 1, or international
+	// with a country code. Years, date ranges and set times are too short or the wrong shape to match.
+	phonePattern = regexp.MustCompile(`(?:\+\d{1,3}(?:[\s.-]?\d{2,4}){3,5}|(?:\b1[\s.-]?)?(?:\(\d{3}\)\s?|\b\d{3}[\s.-]?)\d{3}[\s.-]?\d{4})\b`)
+)
+
+// Moderator filters free-text fields of song requests before they are stored or sent to the band.
+type Moderator struct {
+	Words      map[string]struct{} // lowercase words and phrases that hold a request for review
+	MaxName    int
+	MaxMessage int
+	MaxSong    int
+}
+
+// Result describes what moderation did to a request.
+type Result struct {
+	Flagged bool     `json:"flagged"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// NewModerator returns a Moderator using the comma-separated word list in MODERATION_WORDS.
+func NewModerator() *Moderator {
+	return &Moderator{
+		Words:      ParseWords(os.Getenv("MODERATION_WORDS")),
+		MaxName:    defaultMaxName,
+		MaxMessage: defaultMaxMessage,
+		MaxSong:    defaultMaxSong,
+	}
+}
+
+func ParseWords(list string) map[string]struct{} {
+	words := make(map[string]struct{})
+	for _, word := range strings.Split(list, ",") {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			words[word] = struct{}{}
+		}
+	}
+	return words
+}
+
+// Moderate strips URLs and phone numbers from Name and Message in place, enforces length limits,
+// and flags requests containing listed words. Length violations are returned as errors.
+func (m *Moderator) Moderate(req *request.Request) (Result, error) {
+	var result Result
+	reasons := make(map[string]struct{})
+	for _, field := range []*string{&req.Name, &req.Message} {
+		if urlPattern.MatchString(*field) {
+			*field = urlPattern.ReplaceAllString(*field, "")
+			reasons[ReasonURL] = struct{}{}
+		}
+		if phonePattern.MatchString(*field) {
+			*field = phonePattern.ReplaceAllString(*field, "")
+			reasons[ReasonPhone] = struct{}{}
+		}
+		*field = strings.TrimSpace(spacePattern.ReplaceAllString(*field, " "))
+	}
+
+	if len([]rune(req.Name)) > m.MaxName {
+		return result, fmt.Errorf("name must be %d characters or fewer", m.MaxName)
+	}
+	if len([]rune(req.Message)) > m.MaxMessage {
+		return result, fmt.Errorf("message must be %d characters or fewer", m.MaxMessage)
+	}
+	if len([]rune(req.Song)) > m.MaxSong || len([]rune(req.Artist)) > m.MaxSong {
+		return result, fmt.Errorf("song and artist must be %d characters or fewer", m.MaxSong)
+	}
+
+	for _, text := range []string{req.Name, req.Message, req.Song, req.Artist} {
+		if m.containsWord(text) {
+			result.Flagged = true
+			reasons[ReasonWord] = struct{}{}
+			break
+		}
+	}
+	for _, reason := range []string{ReasonWord, ReasonURL, ReasonPhone} {
+		if _, ok := reasons[reason]; ok {
+			result.Reasons = append(result.Reasons, reason)
+		}
+	}
+	return result, nil
+}
+
+func (m *Moderator) containsWord(text string) bool {
+	if len(m.Words) == 0 {
+		return false
+	}
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	normalized := " " + strings.Join(fields, " ") + " "
+	for word := range m.Words {
+		if strings.Contains(normalized, " "+word+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package moderation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stinkyfingers/chadedwardsapi/request"
+)
+
+func TestModerate(t *testing.T) {
+	m := &Moderator{
+		Words:      ParseWords("darn, heck no"),
+		MaxName:    10,
+		MaxMessage: 40,
+		MaxSong:    20,
+	}
+	tests := []struct {
+		name    string
+		req     request.Request
+		want    request.Request
+		reasons []string
+		flagged bool
+		err     bool
+	}{
+		{
+			name: "clean",
+			req:  request.Request{Name: "Sam", Message: "Play it loud", Song: "Song", Artist: "Band"},
+			want: request.Request{Name: "Sam", Message: "Play it loud", Song: "Song", Artist: "Band"},
+		},
+		{
+			name:    "url",
+			req:     request.Request{Name: "Sam", Message: "see https://example.com/x now"},
+			want:    request.Request{Name: "Sam", Message: "see now"},
+			reasons: []string{ReasonURL},
+		},
+		{
+			name:    "bare domain",
+			req:     request.Request{Name: "spam.io", Message: "hi"},
+			want:    request.Request{Name: "", Message: "hi"},
+			reasons: []string{ReasonURL},
+		},
+		{
+			name:    "phone with dashes",
+			req:     request.Request{Name: "Sam", Message: "call 555-867-5309 ok"},
+			want:    request.Request{Name: "Sam", Message: "call ok"},
+			reasons: []string{ReasonPhone},
+		},
+		{
+			name:    "phone with parentheses",
+			req:     request.Request{Name: "Sam", Message: "(555) 867-5309"},
+			want:    request.Request{Name: "Sam", Message: ""},
+			reasons: []string{ReasonPhone},
+		},
+		{
+			name:    "phone with country code",
+			req:     request.Request{Name: "Sam", Message: "+1 555.867.5309"},
+			want:    request.Request{Name: "Sam", Message: ""},
+			reasons: []string{ReasonPhone},
+		},
+		{
+			name:    "international phone",
+			req:     request.Request{Name: "Sam", Message: "ring +44 20 7946 0958"},
+			want:    request.Request{Name: "Sam", Message: "ring"},
+			reasons: []string{ReasonPhone},
+		},
+		{
+			name:    "digits only",
+			req:     request.Request{Name: "Sam", Message: "5558675309"},
+			want:    request.Request{Name: "Sam", Message: ""},
+			reasons: []string{ReasonPhone},
+		},
+		{
+			name: "years and times",
+			req:  request.Request{Name: "Sam", Message: "tour 2019-2023, set 8:00-10:30"},
+			want: request.Request{Name: "Sam", Message: "tour 2019-2023, set 8:00-10:30"},
+		},
+		{
+			name: "year list",
+			req:  request.Request{Name: "Sam", Message: "1999 2000 2001 2002"},
+			want: request.Request{Name: "Sam", Message: "1999 2000 2001 2002"},
+		},
+		{
+			name: "short number",
+			req:  request.Request{Name: "Sam", Message: "room 867-5309"},
+			want: request.Request{Name: "Sam", Message: "room 867-5309"},
+		},
+		{
+			name:    "word",
+			req:     request.Request{Name: "Sam", Message: "Darn, good band", Song: "Song"},
+			want:    request.Request{Name: "Sam", Message: "Darn, good band", Song: "Song"},
+			reasons: []string{ReasonWord},
+			flagged: true,
+		},
+		{
+			name:    "phrase",
+			req:     request.Request{Name: "Sam", Artist: "Heck  no!"},
+			want:    request.Request{Name: "Sam", Artist: "Heck  no!"},
+			reasons: []string{ReasonWord},
+			flagged: true,
+		},
+		{
+			name: "word inside another",
+			req:  request.Request{Name: "Sam", Message: "darning socks"},
+			want: request.Request{Name: "Sam", Message: "darning socks"},
+		},
+		{
+			name:    "all reasons",
+			req:     request.Request{Name: "Sam", Message: "darn www.x.com 555 867 5309"},
+			want:    request.Request{Name: "Sam", Message: "darn"},
+			reasons: []string{ReasonWord, ReasonURL, ReasonPhone},
+			flagged: true,
+		},
+		{
+			name: "name too long",
+			req:  request.Request{Name: "Samantha Smith"},
+			err:  true,
+		},
+		{
+			name: "message too long",
+			req:  request.Request{Message: "this message is longer than forty characters"},
+			err:  true,
+		},
+		{
+			name: "song too long",
+			req:  request.Request{Song: "a song title that runs on"},
+			err:  true,
+		},
+		{
+			name:    "length measured after stripping",
+			req:     request.Request{Name: "Sam", Message: "hi https://example.com/a/very/long/path/to/something"},
+			want:    request.Request{Name: "Sam", Message: "hi"},
+			reasons: []string{ReasonURL},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			result, err := m.Moderate(&req)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", req)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(req, tt.want) {
+				t.Errorf("request = %+v, want %+v", req, tt.want)
+			}
+			if result.Flagged != tt.flagged {
+				t.Errorf("flagged = %v, want %v", result.Flagged, tt.flagged)
+			}
+			if !reflect.DeepEqual(result.Reasons, tt.reasons) {
+				t.Errorf("reasons = %v, want %v", result.Reasons, tt.reasons)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package photo
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+)
+
+// Album is an ordered collection of photos, keyed by photo filename.
+type Album struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Cover       string    `json:"cover,omitempty"`
+	Date        time.Time `json:"date,omitempty"`
+	Visibility  string    `json:"visibility"`
+	Photos      []string  `json:"photos"`
+}
+
+// Validate checks the album against the known photo metadata and defaults Visibility and Cover.
+// Deleted photos may stay in an album until they are purged; they aren't listed.
+func (a *Album) Validate(metadata map[string]Metadata) error {
+	if a.Title == "" {
+		return fmt.Errorf("title required")
+	}
+	switch a.Visibility {
+	case "":
+		a.Visibility = VisibilityPrivate
+	case VisibilityPublic, VisibilityPrivate:
+	default:
+		return fmt.Errorf("invalid visibility: %s", a.Visibility)
+	}
+	seen := make(map[string]struct{})
+	for _, id := range a.Photos {
+		if _, ok := metadata[id]; !ok {
+			return fmt.Errorf("unknown photo: %s", id)
+		}
+		if _, ok := seen[id]; ok {
+			return fmt.Errorf("duplicate photo: %s", id)
+		}
+		seen[id] = struct{}{}
+	}
+	if a.Cover == "" && len(a.Photos) > 0 {
+		a.Cover = a.Photos[0]
+	}
+	if _, ok := seen[a.Cover]; a.Cover != "" && !ok {
+		return fmt.Errorf("cover must be one of the album's photos")
+	}
+	return nil
+}
+
+// Contains reports whether the album includes the photo.
+func (a *Album) Contains(id string) bool {
+	for _, p := range a.Photos {
+		if p == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove drops the photo from the album, clearing Cover if it was the cover.
+func (a *Album) Remove(id string) bool {
+	for i, p := range a.Photos {
+		if p == id {
+			a.Photos = append(a.Photos[:i], a.Photos[i+1:]...)
+			if a.Cover == id {
+				a.Cover = ""
+				if len(a.Photos) > 0 {
+					a.Cover = a.Photos[0]
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
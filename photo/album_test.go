@@ -0,0 +1,46 @@
+package photo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlbumValidate(t *testing.T) {
+	metadata := map[string]Metadata{
+		"a":       {Filename: "a"},
+		"b":       {Filename: "b"},
+		"trashed": {Filename: "trashed", Deleted: time.Now()},
+	}
+	tests := []struct {
+		name  string
+		album Album
+		cover string
+		err   bool
+	}{
+		{name: "defaults cover", album: Album{Title: "t", Photos: []string{"a", "b"}}, cover: "a"},
+		{name: "deleted member", album: Album{Title: "t", Photos: []string{"trashed", "a"}, Cover: "a"}, cover: "a"},
+		{name: "unknown photo", album: Album{Title: "t", Photos: []string{"a", "nope"}}, err: true},
+		{name: "duplicate photo", album: Album{Title: "t", Photos: []string{"a", "a"}}, err: true},
+		{name: "cover not in album", album: Album{Title: "t", Photos: []string{"a"}, Cover: "b"}, err: true},
+		{name: "no title", album: Album{Photos: []string{"a"}}, err: true},
+		{name: "invalid visibility", album: Album{Title: "t", Visibility: "friends"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			album := tt.album
+			err := album.Validate(metadata)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", album)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if album.Cover != tt.cover {
+				t.Errorf("cover = %q, want %q", album.Cover, tt.cover)
+			}
+		})
+	}
+}
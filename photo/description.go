@@ -0,0 +1,69 @@
+package photo
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxCaption = 1000
+	maxAltText = 250 // screen readers work best with short alt text
+	maxCredit  = 100
+)
+
+// Licenses are the SPDX identifiers a photo may be published under, plus all rights reserved.
+var Licenses = []string{
+	"all-rights-reserved",
+	"CC0-1.0",
+	"CC-BY-4.0",
+	"CC-BY-SA-4.0",
+	"CC-BY-NC-4.0",
+	"CC-BY-NC-SA-4.0",
+	"CC-BY-ND-4.0",
+	"CC-BY-NC-ND-4.0",
+}
+
+func (m Metadata) validateDescription() error {
+	for _, field := range []struct {
+		name  string
+		value string
+		max   int
+	}{
+		{"caption", m.Caption, maxCaption},
+		{"altText", m.AltText, maxAltText},
+		{"credit", m.Credit, maxCredit},
+	} {
+		if !utf8.ValidString(field.value) {
+			return fmt.Errorf("invalid %s: not UTF-8", field.name)
+		}
+		if utf8.RuneCountInString(field.value) > field.max {
+			return fmt.Errorf("%s is longer than %d characters", field.name, field.max)
+		}
+		if strings.IndexFunc(field.value, func(r rune) bool { return unicode.IsControl(r) && r != '\n' }) >= 0 {
+			return fmt.Errorf("invalid %s: contains control characters", field.name)
+		}
+	}
+	if m.AltText != "" && strings.TrimSpace(m.AltText) == "" {
+		return fmt.Errorf("altText is blank")
+	}
+	if m.License != "" && !validLicense(m.License) {
+		return fmt.Errorf("invalid license: %s", m.License)
+	}
+	return nil
+}
+
+func validLicense(license string) bool {
+	for _, l := range Licenses {
+		if l == license {
+			return true
+		}
+	}
+	return false
+}
+
+// MissingAltText reports whether the photo needs alt text written for it.
+func (m Metadata) MissingAltText() bool {
+	return strings.TrimSpace(m.AltText) == ""
+}
@@ -0,0 +1,169 @@
+package photo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	MaxPhotoSize = 50 << 20
+	fetchTimeout = time.Second * 30
+	maxRedirects = 5
+)
+
+var (
+	ErrHostNotAllowed = errors.New("host not allowed")
+	ErrAddrNotAllowed = errors.New("address not allowed")
+	ErrTooLarge       = errors.New("photo exceeds maximum size")
+
+	// AllowedHosts are the hosts, and their subdomains, that photos may be fetched from.
+	AllowedHosts = []string{
+		"googleusercontent.com",
+		"ggpht.com",
+		"photos.google.com",
+	}
+
+	// nonPublicNets are ranges the net.IP predicates don't cover.
+	nonPublicNets = []*net.IPNet{
+		mustParseCIDR("0.0.0.0/8"),     // "this network"
+		mustParseCIDR("100.64.0.0/10"), // carrier-grade NAT
+	}
+
+	fetchClient = newFetchClient()
+)
+
+// newFetchClient returns a client that only talks https to AllowedHosts and refuses to connect to
+// private, loopback and other non-public addresses, checked after DNS resolution so that a public
+// name can't point at an internal address.
+func newFetchClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: time.Second * 10,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: %s", ErrAddrNotAllowed, host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   time.Second * 10,
+			ResponseHeaderTimeout: time.Second * 10,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			return checkURL(req.URL)
+		},
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func checkURL(u *url.URL) error {
+	if u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme must be https", ErrHostNotAllowed)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range AllowedHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+}
+
+// GetGooglePhoto downloads a photo to a temp file which the caller must remove. The URL must be an
+// https URL on one of AllowedHosts; the response must be a supported image format no larger than MaxPhotoSize.
+func GetGooglePhoto(request GooglePhotoRequest) (*os.File, error) {
+	u, err := url.Parse(request.Url)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkURL(u); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching photo: %s", resp.Status)
+	}
+	if resp.ContentLength > MaxPhotoSize {
+		return nil, ErrTooLarge
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, contentType)
+	}
+
+	// check the actual bytes before anything is written to disk
+	body := bufio.NewReaderSize(io.LimitReader(resp.Body, MaxPhotoSize+1), sniffLength)
+	head, err := body.Peek(sniffLength)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !supportedFormat(http.DetectContentType(head)) {
+		return nil, ErrUnsupportedFormat
+	}
+
+	tmp, err := os.CreateTemp("", "photo.*"+filepath.Ext(filepath.Base(request.Filename)))
+	if err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(tmp, body)
+	if err == nil && n > MaxPhotoSize {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tmp, nil
+}
@@ -0,0 +1,41 @@
+package photo
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "142.250.72.97", want: true},
+		{ip: "8.8.8.8", want: true},
+		{ip: "2607:f8b0:4005:80a::2001", want: true},
+		{ip: "100.63.255.255", want: true},
+		{ip: "100.128.0.0", want: true},
+		{ip: "10.0.0.1", want: false},
+		{ip: "172.16.5.4", want: false},
+		{ip: "192.168.1.1", want: false},
+		{ip: "127.0.0.1", want: false},
+		{ip: "169.254.169.254", want: false}, // instance metadata
+		{ip: "0.0.0.0", want: false},
+		{ip: "0.1.2.3", want: false},
+		{ip: "100.64.0.1", want: false},
+		{ip: "100.127.255.254", want: false},
+		{ip: "224.0.0.1", want: false},
+		{ip: "::1", want: false},
+		{ip: "::", want: false},
+		{ip: "fe80::1", want: false},
+		{ip: "fd00::1", want: false},
+		{ip: "ff02::1", want: false},
+		{ip: "::ffff:10.0.0.1", want: false},
+		{ip: "::ffff:100.64.0.1", want: false},
+	}
+	for _, tt := range tests {
+		if got := isPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
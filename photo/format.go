@@ -0,0 +1,98 @@
+package photo
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+
+	"golang.org/x/image/webp"
+)
+
+const (
+	FormatJPEG = "image/jpeg"
+	FormatPNG  = "image/png"
+	FormatGIF  = "image/gif"
+	FormatWebP = "image/webp"
+
+	sniffLength = 512
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// DetectFormat sniffs the image format from the file's content, ignoring its name and any declared mime type.
+func DetectFormat(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, sniffLength)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	format := http.DetectContentType(buf[:n])
+	if !supportedFormat(format) {
+		return "", ErrUnsupportedFormat
+	}
+	return format, nil
+}
+
+func supportedFormat(format string) bool {
+	switch format {
+	case FormatJPEG, FormatPNG, FormatGIF, FormatWebP:
+		return true
+	}
+	return false
+}
+
+// Normalize returns a JPEG version of the image at name and its detected format. JPEGs are
+// returned as-is so that their EXIF data is preserved; PNG, GIF (first frame) and WebP images
+// are flattened onto white and re-encoded to a temp file, which the caller must remove when
+// it differs from name.
+func Normalize(name string) (string, string, error) {
+	format, err := DetectFormat(name)
+	if err != nil {
+		return "", "", err
+	}
+	if format == FormatJPEG {
+		return name, format, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	var src image.Image
+	switch format {
+	case FormatPNG:
+		src, err = png.Decode(f)
+	case FormatGIF:
+		src, err = gif.Decode(f)
+	case FormatWebP:
+		src, err = webp.Decode(f)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	jpg, err := writeTempJPEG(flatten(src), publicQuality)
+	if err != nil {
+		return "", "", err
+	}
+	return jpg, format, nil
+}
+
+// flatten composites src over an opaque white background.
+func flatten(src image.Image) image.Image {
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,153 @@
+package photo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+const (
+	positionStackEndpoint = "https://api.positionstack.com/v1/"
+	cachePrecision        = 3 // decimal places of cache keys, ~110m
+)
+
+// Geocoder reverse geocodes coordinates. A nil Location with a nil error means nothing was found.
+type Geocoder interface {
+	Reverse(ctx context.Context, lat, long float64) (*Location, error)
+}
+
+// PositionStack reverse geocodes with the PositionStack API. Its API only accepts the key as a
+// query parameter, so errors are stripped of the request URL to keep the key out of logs.
+type PositionStack struct {
+	Key      string
+	Endpoint string
+	Client   *http.Client
+}
+
+func NewPositionStack(key string) *PositionStack {
+	return &PositionStack{
+		Key:      key,
+		Endpoint: positionStackEndpoint,
+		Client:   &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+func (p *PositionStack) Reverse(ctx context.Context, lat, long float64) (*Location, error) {
+	query := url.Values{}
+	query.Set("access_key", p.Key)
+	query.Set("query", fmt.Sprintf("%f,%f", lat, long))
+	req, err := http.NewRequestWithContext(ctx, "GET", p.Endpoint+"reverse?"+query.Encode(), nil)
+	if err != nil {
+		return nil, errors.New("positionstack: invalid request")
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("positionstack: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("positionstack: %s", resp.Status)
+	}
+
+	var positionStackResponse PositionStackResponse
+	err = json.NewDecoder(resp.Body).Decode(&positionStackResponse)
+	if err != nil {
+		return nil, err
+	}
+	return BestLocation(positionStackResponse.Data), nil
+}
+
+// Cache stores reverse geocoding results by key. Found reports whether key was cached, even if
+// the cached location is nil.
+type Cache interface {
+	Get(key string) (location *Location, found bool)
+	Set(key string, location *Location) error
+}
+
+// CachedGeocoder consults Cache, keyed by coordinates rounded to ~110m, before Geocoder.
+type CachedGeocoder struct {
+	Geocoder Geocoder
+	Cache    Cache
+}
+
+func NewCachedGeocoder(geocoder Geocoder, cache Cache) *CachedGeocoder {
+	return &CachedGeocoder{
+		Geocoder: geocoder,
+		Cache:    cache,
+	}
+}
+
+func (c *CachedGeocoder) Reverse(ctx context.Context, lat, long float64) (*Location, error) {
+	key := fmt.Sprintf("%.*f,%.*f", cachePrecision, lat, cachePrecision, long)
+	if location, found := c.Cache.Get(key); found {
+		return location, nil
+	}
+	location, err := c.Geocoder.Reverse(ctx, lat, long)
+	if err != nil {
+		return nil, err
+	}
+	return location, c.Cache.Set(key, location)
+}
+
+// StorageCache persists geocoding results as a single object in the api bucket.
+type StorageCache struct {
+	Storage storage.Storage
+
+	mu        sync.Mutex
+	locations map[string]*Location
+}
+
+func NewStorageCache(store storage.Storage) *StorageCache {
+	return &StorageCache{
+		Storage: store,
+	}
+}
+
+func (c *StorageCache) Get(key string) (*Location, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return nil, false
+	}
+	location, found := c.locations[key]
+	return location, found
+}
+
+func (c *StorageCache) Set(key string, location *Location) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return err
+	}
+	c.locations[key] = location
+	return c.Storage.Write(storage.BUCKET_API, storage.KEY_GEOCODE_CACHE, c.locations)
+}
+
+func (c *StorageCache) load() error {
+	if c.locations != nil {
+		return nil
+	}
+	r, err := c.Storage.Get(storage.BUCKET_API, storage.KEY_GEOCODE_CACHE)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	locations := make(map[string]*Location)
+	if err = json.NewDecoder(r).Decode(&locations); err != nil && err != io.EOF {
+		return err
+	}
+	c.locations = locations
+	return nil
+}
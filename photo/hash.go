@@ -0,0 +1,121 @@
+package photo
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+)
+
+// DuplicateThreshold is the maximum Hamming distance between the hashes of two near-duplicate photos.
+const DuplicateThreshold = 10
+
+// Hash returns the 64-bit difference hash (dHash) of img as hex: the image is reduced to 9x8
+// grayscale and each bit records whether a pixel is brighter than its right-hand neighbour. It is
+// unaffected by scaling and recompression, so the same photo from different phones hashes closely.
+func Hash(img image.Image) string {
+	small := imaging.Resize(imaging.Grayscale(img), 9, 8, imaging.Box)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := small.Pix[small.PixOffset(x, y)]
+			right := small.Pix[small.PixOffset(x+1, y)]
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", hash)
+}
+
+// HashReader decodes an image from r, applying its EXIF orientation, and returns its Hash.
+func HashReader(r io.Reader) (string, error) {
+	img, err := Decode(r)
+	if err != nil {
+		return "", err
+	}
+	return Hash(img), nil
+}
+
+// HashDistance returns the number of differing bits between two hashes.
+func HashDistance(a, b string) (int, error) {
+	x, err := strconv.ParseUint(a, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hash %q", a)
+	}
+	y, err := strconv.ParseUint(b, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hash %q", b)
+	}
+	return bits.OnesCount64(x ^ y), nil
+}
+
+// Duplicates returns the sorted keys of photos in metadata, other than id, whose hash is within
+// threshold of hash.
+func Duplicates(metadata map[string]Metadata, id, hash string, threshold int) []string {
+	var keys []string
+	for k, m := range metadata {
+		if k == id || m.Hash == "" || m.IsDeleted() {
+			continue
+		}
+		if distance, err := HashDistance(hash, m.Hash); err == nil && distance <= threshold {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DuplicateClusters groups photos whose hashes are within threshold of each other, transitively.
+// Photos without duplicates are omitted. Clusters and their keys are sorted.
+func DuplicateClusters(metadata map[string]Metadata, threshold int) [][]string {
+	var keys []string
+	for k, m := range metadata {
+		if m.Hash != "" && !m.IsDeleted() {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	// union-find over keys
+	parent := make([]int, len(keys))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range keys {
+		for j := i + 1; j < len(keys); j++ {
+			distance, err := HashDistance(metadata[keys[i]].Hash, metadata[keys[j]].Hash)
+			if err == nil && distance <= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := make(map[int][]string)
+	for i, k := range keys {
+		root := find(i)
+		groups[root] = append(groups[root], k)
+	}
+	var clusters [][]string
+	for _, group := range groups {
+		if len(group) > 1 {
+			clusters = append(clusters, group)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters
+}
@@ -0,0 +1,137 @@
+package photo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// pattern returns a w x h image of smooth waves with the given periods, in pixels.
+func pattern(w, h int, px, py float64) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			v := uint8(128 + 127*math.Sin(float64(x)/px)*math.Cos(float64(y)/py))
+			img.Set(x, y, color.RGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func TestHash(t *testing.T) {
+	src := pattern(640, 480, 60, 45)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatal(err)
+	}
+	recompressed, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		img       image.Image
+		duplicate bool
+	}{
+		{name: "identical", img: src, duplicate: true},
+		{name: "rescaled", img: imaging.Resize(src, 160, 120, imaging.Lanczos), duplicate: true},
+		{name: "recompressed", img: recompressed, duplicate: true},
+		{name: "different", img: pattern(640, 480, 25, 80), duplicate: false},
+		{name: "mirrored", img: imaging.FlipH(src), duplicate: false},
+	}
+	hash := Hash(src)
+	if len(hash) != 16 {
+		t.Fatalf("hash %q is not 16 hex digits", hash)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance, err := HashDistance(hash, Hash(tt.img))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := distance <= DuplicateThreshold; got != tt.duplicate {
+				t.Errorf("distance = %d, duplicate = %v, want %v", distance, got, tt.duplicate)
+			}
+			if tt.name == "identical" && distance != 0 {
+				t.Errorf("distance = %d, want 0", distance)
+			}
+		})
+	}
+}
+
+func TestHashDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+		err  bool
+	}{
+		{a: "0000000000000000", b: "0000000000000000", want: 0},
+		{a: "0000000000000000", b: "ffffffffffffffff", want: 64},
+		{a: "00000000000000f0", b: "000000000000000f", want: 8},
+		{a: "8000000000000001", b: "0000000000000000", want: 2},
+		{a: "", b: "0000000000000000", err: true},
+		{a: "0000000000000000", b: "not a hash", err: true},
+		{a: "10000000000000000", b: "0000000000000000", err: true}, // more than 64 bits
+	}
+	for _, tt := range tests {
+		got, err := HashDistance(tt.a, tt.b)
+		if tt.err {
+			if err == nil {
+				t.Errorf("HashDistance(%q, %q) = %d, want error", tt.a, tt.b, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("HashDistance(%q, %q): %v", tt.a, tt.b, err)
+		} else if got != tt.want {
+			t.Errorf("HashDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	metadata := map[string]Metadata{
+		"self":    {Hash: "0000000000000000"},
+		"near":    {Hash: "00000000000000ff"}, // 8 bits off
+		"far":     {Hash: "0000000000ffffff"}, // 24 bits off
+		"nohash":  {},
+		"invalid": {Hash: "zz"},
+		"trashed": {Hash: "0000000000000000", Deleted: time.Now()},
+	}
+	got := Duplicates(metadata, "self", "0000000000000000", DuplicateThreshold)
+	if want := []string{"near"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Duplicates() = %v, want %v", got, want)
+	}
+}
+
+func TestDuplicateClusters(t *testing.T) {
+	metadata := map[string]Metadata{
+		// a-b and b-c are within the threshold, a-c isn't: clustered transitively
+		"a": {Hash: "0000000000000000"},
+		"b": {Hash: "00000000000000ff"},
+		"c": {Hash: "000000000000ffff"},
+		// a pair of their own
+		"d": {Hash: "ffffffffffffffff"},
+		"e": {Hash: "fffffffffffffff0"},
+		// alone
+		"f": {Hash: "ff00ff00ff00ff00"},
+		"j": {},
+		// deleted photos don't join clusters, or bridge c and i
+		"g": {Hash: "ffffffffffffffff", Deleted: time.Now()},
+		"h": {Hash: "0000000000ffffff", Deleted: time.Now()},
+		"i": {Hash: "00000000ffffffff"},
+	}
+	got := DuplicateClusters(metadata, DuplicateThreshold)
+	want := [][]string{{"a", "b", "c"}, {"d", "e"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DuplicateClusters() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package photo
+
+import (
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	earthRadius        = 6371.0 // km
+	offlineMaxDistance = 100.0  // km
+)
+
+//go:embed data/places.csv
+var bundledPlaces string
+
+// Offline reverse geocodes to the nearest place in a local dataset, without network access.
+type Offline struct {
+	Places      []Place
+	MaxDistance float64 // km; coordinates farther than this from every place return nil
+}
+
+// Place is a row of the offline places dataset.
+type Place struct {
+	Location
+	Latitude  float64
+	Longitude float64
+}
+
+// NewOffline loads the places dataset from PLACES_FILE, or the bundled dataset if unset. The bundled
+// dataset only has large US cities; for coverage elsewhere, point PLACES_FILE at a gazetteer such as
+// GeoNames cities15000 converted to the ReadPlaces columns.
+func NewOffline() (*Offline, error) {
+	var r io.Reader = strings.NewReader(bundledPlaces)
+	if name := os.Getenv("PLACES_FILE"); name != "" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	places, err := ReadPlaces(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Offline{
+		Places:      places,
+		MaxDistance: offlineMaxDistance,
+	}, nil
+}
+
+// ReadPlaces reads a CSV with a header row and the columns name, region, region_code, country,
+// country_code, latitude, longitude.
+func ReadPlaces(r io.Reader) ([]Place, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 7
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, errors.New("places dataset is empty")
+	}
+
+	var places []Place
+	for i, record := range records[1:] {
+		lat, err := strconv.ParseFloat(record[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("places row %d: invalid latitude", i+2)
+		}
+		long, err := strconv.ParseFloat(record[6], 64)
+		if err != nil {
+			return nil, fmt.Errorf("places row %d: invalid longitude", i+2)
+		}
+		places = append(places, Place{
+			Location: Location{
+				Label:       fmt.Sprintf("%s, %s, %s", record[0], record[2], record[4]),
+				Name:        record[0],
+				Type:        "locality",
+				Region:      record[1],
+				RegionCode:  record[2],
+				Country:     record[3],
+				CountryCode: record[4],
+			},
+			Latitude:  lat,
+			Longitude: long,
+		})
+	}
+	return places, nil
+}
+
+func (o *Offline) Reverse(ctx context.Context, lat, long float64) (*Location, error) {
+	var nearest *Place
+	nearestDistance := math.Inf(1)
+	for i := range o.Places {
+		distance := haversine(lat, long, o.Places[i].Latitude, o.Places[i].Longitude)
+		if distance < nearestDistance {
+			nearest, nearestDistance = &o.Places[i], distance
+		}
+	}
+	if nearest == nil || nearestDistance > o.MaxDistance {
+		// the bundled dataset only has US cities; PLACES_FILE or PositionStack covers the rest
+		log.Printf("offline geocoder: no place within %.0fkm of %.4f,%.4f", o.MaxDistance, lat, long)
+		return nil, nil
+	}
+
+	location := nearest.Location
+	location.Confidence = math.Round((1-nearestDistance/o.MaxDistance)*100) / 100
+	return &location, nil
+}
+
+// haversine returns the great-circle distance in km between two coordinates.
+func haversine(lat1, long1, lat2, long2 float64) float64 {
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLong := toRadians(long2 - long1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
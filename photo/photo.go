@@ -1,38 +1,66 @@
 package photo
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
 	"fmt"
 	"image"
-	"image/color"
-	"image/draw"
-	"image/jpeg"
-	"image/png"
+	_ "image/jpeg" // register decoder for image.DecodeConfig
 	"io"
 	"log"
-	"net/http"
+	"math"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/disintegration/imaging"
 	"github.com/rwcarlsen/goexif/exif"
 	"github.com/rwcarlsen/goexif/mknote"
 )
 
 type Metadata struct {
-	Filename         string    `json:"filename"`
-	DateTimeOriginal time.Time `json:"datetimeOriginal,omitempty"`
-	GPSLongitude     float64   `json:"gpsLongitude,omitempty"`
-	GPSLatitude      float64   `json:"gpsLatitude,omitempty"`
-	Location         *Location `json:"location,omitempty"`
-	Category         string    `json:"category,omitempty"`
-	Tags             []string  `json:"tags,omitempty"`
+	Filename         string      `json:"filename"`
+	DateTimeOriginal time.Time   `json:"datetimeOriginal,omitempty"`
+	GPSLongitude     float64     `json:"gpsLongitude,omitempty"`
+	GPSLatitude      float64     `json:"gpsLatitude,omitempty"`
+	Location         *Location   `json:"location,omitempty"`
+	Category         string      `json:"category,omitempty"`
+	Tags             []string    `json:"tags,omitempty"`
+	Uploaded         time.Time   `json:"uploaded,omitempty"`
+	Renditions       []Rendition `json:"renditions,omitempty"`
+	Width            int         `json:"width,omitempty"`
+	Height           int         `json:"height,omitempty"`
+	AspectRatio      float64     `json:"aspectRatio,omitempty"`
+	Camera           *Camera     `json:"camera,omitempty"`
+	LocationPrivacy  string      `json:"locationPrivacy,omitempty"`
+	Caption          string      `json:"caption,omitempty"`
+	AltText          string      `json:"altText,omitempty"`
+	Credit           string      `json:"credit,omitempty"` // photographer
+	License          string      `json:"license,omitempty"`
+	Hash             string      `json:"hash,omitempty"` // perceptual hash, see Hash
+	BlurHash         string      `json:"blurHash,omitempty"`
+	AverageColor     string      `json:"averageColor,omitempty"`
+	DominantColor    string      `json:"dominantColor,omitempty"`
+	Deleted          time.Time   `json:"deleted,omitempty"` // moved to the trash
 }
 
 type ExifData struct {
 	DateTimeOriginal time.Time
 	GPSLongitude     float64
 	GPSLatitude      float64
+	Orientation      int
+	Width            int // as displayed, i.e. after applying Orientation
+	Height           int
+	Camera           *Camera
+}
+
+type Camera struct {
+	Make     string  `json:"make,omitempty"`
+	Model    string  `json:"model,omitempty"`
+	Lens     string  `json:"lens,omitempty"`
+	Exposure string  `json:"exposure,omitempty"` // seconds, e.g. 1/250
+	FNumber  float64 `json:"fNumber,omitempty"`
+	ISO      int     `json:"iso,omitempty"`
 }
 
 type PositionStackResponse struct {
@@ -68,16 +96,29 @@ type writerSkipper struct {
 	bytesToSkip int
 }
 
-const (
-	positionStackEndpoint = "http://api.positionstack.com/v1/"
-)
-
+// GetExifData reads EXIF data and the image dimensions from r. Images without EXIF data
+// still return their dimensions.
 func GetExifData(r io.Reader) (*ExifData, error) {
-	exif.RegisterParsers(mknote.All...)
-	x, err := exif.Decode(r)
+	b, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	data := &ExifData{
+		Orientation: 1,
+		Width:       config.Width,
+		Height:      config.Height,
+	}
+
+	exif.RegisterParsers(mknote.All...)
+	x, err := exif.Decode(bytes.NewReader(b))
+	if err != nil {
+		log.Println("error decoding exif", err)
+		return data, nil
+	}
 	datetime, err := x.DateTime()
 	if err != nil {
 		log.Println("error getting datetime", err)
@@ -86,32 +127,95 @@ func GetExifData(r io.Reader) (*ExifData, error) {
 	if err != nil {
 		log.Println("error getting latlong", err)
 	}
-
-	return &ExifData{
-		DateTimeOriginal: datetime,
-		GPSLongitude:     long,
-		GPSLatitude:      lat,
-	}, nil
+	data.DateTimeOriginal = datetime
+	data.GPSLongitude = long
+	data.GPSLatitude = lat
+	if tag, err := x.Get(exif.Orientation); err == nil {
+		if orientation, err := tag.Int(0); err == nil && orientation >= 1 && orientation <= 8 {
+			data.Orientation = orientation
+		}
+	}
+	if data.Orientation >= 5 { // 5-8 are rotated 90 degrees
+		data.Width, data.Height = data.Height, data.Width
+	}
+	data.Camera = getCamera(x)
+	return data, nil
 }
 
-func (e *ExifData) GetLocation() (*Location, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%sreverse?access_key=%s&query=%f,%f", positionStackEndpoint, os.Getenv("POSITIONSTACK_KEY"), e.GPSLatitude, e.GPSLongitude), nil)
+// GetFileExifData reads EXIF data from the named file.
+func GetFileExifData(name string) (*ExifData, error) {
+	f, err := os.Open(name)
 	if err != nil {
 		return nil, err
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	defer f.Close()
+	return GetExifData(f)
+}
+
+func getCamera(x *exif.Exif) *Camera {
+	var camera Camera
+	stringVal := func(field exif.FieldName) string {
+		tag, err := x.Get(field)
+		if err != nil {
+			return ""
+		}
+		val, err := tag.StringVal()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(strings.TrimRight(val, "\x00"))
+	}
+	camera.Make = stringVal(exif.Make)
+	camera.Model = stringVal(exif.Model)
+	camera.Lens = stringVal(exif.LensModel)
+	if tag, err := x.Get(exif.ExposureTime); err == nil {
+		if num, den, err := tag.Rat2(0); err == nil && num > 0 && den > 0 {
+			if num < den {
+				camera.Exposure = fmt.Sprintf("1/%d", (den+num/2)/num)
+			} else {
+				camera.Exposure = strconv.FormatFloat(float64(num)/float64(den), 'f', -1, 64)
+			}
+		}
+	}
+	if tag, err := x.Get(exif.FNumber); err == nil {
+		if num, den, err := tag.Rat2(0); err == nil && den > 0 {
+			camera.FNumber = float64(num) / float64(den)
+		}
+	}
+	if tag, err := x.Get(exif.ISOSpeedRatings); err == nil {
+		if iso, err := tag.Int(0); err == nil {
+			camera.ISO = iso
+		}
+	}
+	if camera == (Camera{}) {
+		return nil
 	}
-	defer resp.Body.Close()
+	return &camera
+}
 
-	var positionStackResponse PositionStackResponse
-	err = json.NewDecoder(resp.Body).Decode(&positionStackResponse)
-	if err != nil {
-		return nil, err
+// Apply copies EXIF-derived fields into m. Date, GPS and camera are only copied when present,
+// so applying data from a stripped image keeps what was extracted from the original.
+func (e *ExifData) Apply(m *Metadata) {
+	if !e.DateTimeOriginal.IsZero() {
+		m.DateTimeOriginal = e.DateTimeOriginal
+	}
+	if e.GPSLatitude != 0 || e.GPSLongitude != 0 {
+		m.GPSLatitude = e.GPSLatitude
+		m.GPSLongitude = e.GPSLongitude
+	}
+	m.Width = e.Width
+	m.Height = e.Height
+	if e.Height > 0 {
+		m.AspectRatio = math.Round(float64(e.Width)/float64(e.Height)*1000) / 1000
+	}
+	if e.Camera != nil {
+		m.Camera = e.Camera
 	}
-	return BestLocation(positionStackResponse.Data), nil
+}
+
+// GetLocation reverse geocodes the photo's GPS coordinates.
+func (e *ExifData) GetLocation(ctx context.Context, geocoder Geocoder) (*Location, error) {
+	return geocoder.Reverse(ctx, e.GPSLatitude, e.GPSLongitude)
 }
 
 func BestLocation(locations []Location) *Location {
@@ -129,81 +233,11 @@ func BestLocation(locations []Location) *Location {
 }
 
 func CreateThumbnail(name string) (string, error) {
-	f, err := os.Open(name)
+	renditions, err := CreateRenditions(name, []RenditionSpec{{Width: thumbnailSize, Square: true}})
 	if err != nil {
 		return "", err
 	}
-	defer f.Close()
-	src, _, err := image.Decode(f)
-	if err != nil {
-		return "", err
-	}
-	dst := imaging.Thumbnail(src, 100, 100, imaging.CatmullRom)
-	tmp, err := os.CreateTemp("", "photo.*.jpeg")
-	if err != nil {
-		return "", err
-	}
-
-	err = imaging.Encode(tmp, dst, imaging.JPEG)
-	if err != nil {
-		return "", err
-	}
-	return tmp.Name(), nil
-}
-
-func GetGooglePhoto(request GooglePhotoRequest) (*os.File, error) {
-	cli := &http.Client{}
-	req, err := http.NewRequest("GET", request.Url, nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := cli.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	tmp, err := os.CreateTemp("", request.Filename)
-	if err != nil {
-		return nil, err
-	}
-	_, err = io.Copy(tmp, resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return tmp, nil
-}
-
-func PngToJpg(name string, w io.WriteSeeker) error {
-	f, err := os.Open(name)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	src, err := png.Decode(f)
-	if err != nil {
-		return err
-	}
-	img := image.NewRGBA(src.Bounds())
-	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
-	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Over)
-	tmp, err := os.CreateTemp("", "photo.*.jpeg")
-	if err != nil {
-		return err
-	}
-	defer os.Remove(tmp.Name())
-	opt := &jpeg.Options{
-		Quality: 90,
-	}
-	err = jpeg.Encode(tmp, img, opt)
-	if err != nil {
-		return err
-	}
-	_, err = w.Seek(0, 0)
-	if err != nil {
-		return err
-	}
-	_, err = io.Copy(w, tmp)
-	return err
+	return renditions[0].File, nil
 }
 
 func UpdateMetadata(src, dst map[string]Metadata) {
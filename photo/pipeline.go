@@ -0,0 +1,108 @@
+package photo
+
+import (
+	"context"
+	"image"
+	"log"
+	"os"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+// Pipeline turns image files into stored photos. It is shared by the upload endpoints, the worker
+// and the admin CLI.
+type Pipeline struct {
+	Storage   storage.Storage
+	Geocoder  Geocoder   // nil skips reverse geocoding
+	Watermark *Watermark // nil disables watermarking
+}
+
+// Process runs an image file through the pipeline: format normalization, renditions, private
+// original, public copy without EXIF, and metadata extraction. It returns the metadata to store
+// under id. Temp files other than name are removed before returning.
+func (p *Pipeline) Process(ctx context.Context, id, name string, metadata Metadata) (Metadata, error) {
+	// sniff the content rather than trusting the mime type or extension, and convert to JPEG
+	jpg, _, err := Normalize(name)
+	if err != nil {
+		return metadata, err
+	}
+	if jpg != name {
+		defer os.Remove(jpg)
+	}
+
+	img, err := Open(jpg)
+	if err != nil {
+		return metadata, err
+	}
+	metadata.Hash = Hash(img)
+	metadata.SetPlaceholders(img)
+
+	// thumbnail and resized renditions
+	renditions, err := p.UploadRenditions(id, img, Renditions)
+	if err != nil {
+		return metadata, err
+	}
+
+	// keep the original privately; serve a copy without EXIF/GPS
+	if err = p.Storage.UploadPrivate(storage.BUCKET_ORIGINALS, id, name); err != nil {
+		return metadata, err
+	}
+	if err = p.UploadImage(id, img); err != nil {
+		return metadata, err
+	}
+
+	exifData, err := GetFileExifData(name)
+	if err != nil {
+		return metadata, err
+	}
+	exifData.Apply(&metadata)
+	if metadata.Location == nil && (metadata.GPSLatitude != 0 || metadata.GPSLongitude != 0) && p.Geocoder != nil {
+		location, err := exifData.GetLocation(ctx, p.Geocoder)
+		if err != nil {
+			log.Print("error geocoding ", id, ": ", err) // location can be backfilled later
+		}
+		metadata.Location = location
+	}
+	metadata.Filename = id
+	metadata.Uploaded = time.Now()
+	metadata.Renditions = renditions
+	return metadata, nil
+}
+
+// UploadRenditions generates specs from img, watermarked, and uploads them for photo id.
+func (p *Pipeline) UploadRenditions(id string, img image.Image, specs []RenditionSpec) ([]Rendition, error) {
+	renditions, err := WatermarkedRenditions(img, specs, p.Watermark)
+	if err != nil {
+		return nil, err
+	}
+	defer RemoveRenditions(renditions)
+	for i, rendition := range renditions {
+		bucket, key := RenditionObject(id, rendition.Name)
+		if err = p.Storage.Upload(bucket, key, rendition.File); err != nil {
+			return nil, err
+		}
+		renditions[i].File = ""
+	}
+	return renditions, nil
+}
+
+// UploadImage uploads the public copy of img for photo id: watermarked, without metadata.
+func (p *Pipeline) UploadImage(id string, img image.Image) error {
+	stripped, err := StripImage(p.Watermark.Apply(img))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(stripped)
+	return p.Storage.Upload(storage.BUCKET_IMAGES, id, stripped)
+}
+
+// Remove deletes every object Process may have written for id, after a failure.
+func (p *Pipeline) Remove(id string) {
+	p.Storage.Delete(storage.BUCKET_IMAGES, id)
+	p.Storage.Delete(storage.BUCKET_ORIGINALS, id)
+	for _, spec := range Renditions {
+		bucket, key := RenditionObject(id, spec.Name())
+		p.Storage.Delete(bucket, key)
+	}
+}
@@ -0,0 +1,161 @@
+package photo
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	blurHashX          = 4 // horizontal components
+	blurHashY          = 3 // vertical components
+	placeholderSize    = 32
+	blurHashCharacters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+)
+
+// SetPlaceholders sets the BlurHash, AverageColor and DominantColor of m from img, so that
+// galleries can reserve and fill the space for a photo before its thumbnail loads.
+func (m *Metadata) SetPlaceholders(img image.Image) {
+	small := imaging.Resize(img, placeholderSize, 0, imaging.Box)
+	m.BlurHash = BlurHash(small, blurHashX, blurHashY)
+	m.AverageColor, m.DominantColor = Colors(small)
+}
+
+// BlurHash encodes img with the given number of components (1-9 in each direction). See
+// https://github.com/woltapp/blurhash. img should be small; every pixel contributes to every component.
+func BlurHash(img image.Image, xComponents, yComponents int) string {
+	nrgba := imaging.Clone(img)
+	width, height := nrgba.Bounds().Dx(), nrgba.Bounds().Dy()
+
+	// linear RGB of each pixel, computed once
+	linear := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := nrgba.PixOffset(x, y)
+			linear[y*width+x] = [3]float64{
+				sRGBToLinear(nrgba.Pix[i]),
+				sRGBToLinear(nrgba.Pix[i+1]),
+				sRGBToLinear(nrgba.Pix[i+2]),
+			}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var factor [3]float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := math.Cos(math.Pi*float64(i*x)/float64(width)) *
+						math.Cos(math.Pi*float64(j*y)/float64(height))
+					for c := range factor {
+						factor[c] += basis * linear[y*width+x][c]
+					}
+				}
+			}
+			scale := normalisation / float64(width*height)
+			for c := range factor {
+				factor[c] *= scale
+			}
+			factors = append(factors, factor)
+		}
+	}
+
+	var hash strings.Builder
+	encode83(&hash, (xComponents-1)+(yComponents-1)*9, 1)
+
+	dc, ac := factors[0], factors[1:]
+	maximum := 1.0
+	if len(ac) > 0 {
+		var actualMaximum float64
+		for _, factor := range ac {
+			for _, v := range factor {
+				actualMaximum = math.Max(actualMaximum, math.Abs(v))
+			}
+		}
+		quantisedMaximum := int(math.Max(0, math.Min(82, math.Floor(actualMaximum*166-0.5))))
+		maximum = float64(quantisedMaximum+1) / 166
+		encode83(&hash, quantisedMaximum, 1)
+	} else {
+		encode83(&hash, 0, 1)
+	}
+
+	encode83(&hash, linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+	for _, factor := range ac {
+		value := 0
+		for _, v := range factor {
+			quantised := int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximum, 0.5)*9+9.5))))
+			value = value*19 + quantised
+		}
+		encode83(&hash, value, 2)
+	}
+	return hash.String()
+}
+
+// Colors returns the average and dominant colors of img as #rrggbb. The dominant color is the
+// average of the most common bucket of similar colors.
+func Colors(img image.Image) (average, dominant string) {
+	nrgba := imaging.Clone(img)
+	var (
+		total   [3]int
+		count   int
+		buckets = make(map[int]*[4]int) // r, g, b totals and count
+		best    *[4]int
+	)
+	for i := 0; i+3 < len(nrgba.Pix); i += 4 {
+		r, g, b := int(nrgba.Pix[i]), int(nrgba.Pix[i+1]), int(nrgba.Pix[i+2])
+		total[0], total[1], total[2] = total[0]+r, total[1]+g, total[2]+b
+		count++
+
+		key := r>>4<<8 | g>>4<<4 | b>>4
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &[4]int{}
+			buckets[key] = bucket
+		}
+		bucket[0], bucket[1], bucket[2], bucket[3] = bucket[0]+r, bucket[1]+g, bucket[2]+b, bucket[3]+1
+		if best == nil || bucket[3] > best[3] {
+			best = bucket
+		}
+	}
+	if count == 0 {
+		return "", ""
+	}
+	average = fmt.Sprintf("#%02x%02x%02x", total[0]/count, total[1]/count, total[2]/count)
+	dominant = fmt.Sprintf("#%02x%02x%02x", best[0]/best[3], best[1]/best[3], best[2]/best[3])
+	return average, dominant
+}
+
+func encode83(b *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := value / int(math.Pow(83, float64(length-i))) % 83
+		b.WriteByte(blurHashCharacters[digit])
+	}
+}
+
+func sRGBToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
@@ -0,0 +1,109 @@
+package photo
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func decode83(t *testing.T, s string) int {
+	t.Helper()
+	value := 0
+	for _, c := range s {
+		digit := strings.IndexRune(blurHashCharacters, c)
+		if digit < 0 {
+			t.Fatalf("%q is not base 83", s)
+		}
+		value = value*83 + digit
+	}
+	return value
+}
+
+// acComponents returns the quantised red, green and blue of each AC component of hash, 0-18 with 9 as zero.
+func acComponents(t *testing.T, hash string) [][3]int {
+	t.Helper()
+	var components [][3]int
+	for i := 6; i+2 <= len(hash); i += 2 {
+		v := decode83(t, hash[i:i+2])
+		components = append(components, [3]int{v / (19 * 19), v / 19 % 19, v % 19})
+	}
+	return components
+}
+
+func fill(img *image.NRGBA, rect image.Rectangle, c color.Color) {
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			img.Set(x, y, c)
+		}
+	}
+}
+
+func TestBlurHash(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+
+	solid := image.NewNRGBA(image.Rect(0, 0, 16, 12))
+	fill(solid, solid.Bounds(), color.NRGBA{0x12, 0x34, 0x56, 255})
+	hash := BlurHash(solid, blurHashX, blurHashY)
+	if len(hash) != 4+2*blurHashX*blurHashY {
+		t.Fatalf("len(%q) = %d, want %d", hash, len(hash), 4+2*blurHashX*blurHashY)
+	}
+	if size := decode83(t, hash[:1]); size != (blurHashX-1)+(blurHashY-1)*9 {
+		t.Errorf("size flag = %d", size)
+	}
+	if dc := decode83(t, hash[2:6]); dc != 0x123456 {
+		t.Errorf("dc = %06x, want 123456", dc)
+	}
+	// the cosine sums over whole pixels leave only a trace of AC in a solid image
+	solidMaximum := decode83(t, hash[1:2])
+
+	// red on the left, blue on the right
+	split := image.NewNRGBA(image.Rect(0, 0, 16, 12))
+	fill(split, image.Rect(0, 0, 8, 12), red)
+	fill(split, image.Rect(8, 0, 16, 12), blue)
+	hash = BlurHash(split, blurHashX, blurHashY)
+	if maximum := decode83(t, hash[1:2]); maximum < 10*solidMaximum {
+		t.Errorf("quantised ac maximum = %d, want far more than a solid image's %d", maximum, solidMaximum)
+	}
+	ac := acComponents(t, hash)
+	if len(ac) != blurHashX*blurHashY-1 {
+		t.Fatalf("%d ac components, want %d", len(ac), blurHashX*blurHashY-1)
+	}
+	if first := ac[0]; first[0] <= 9 || first[1] != 9 || first[2] >= 9 {
+		t.Errorf("first horizontal component = %v, want more red and less blue on the left", first)
+	}
+	// components that don't vary horizontally see as much red as blue
+	for j := 1; j < blurHashY; j++ {
+		if c := ac[j*blurHashX-1]; c[0] != c[2] || c[1] != 9 {
+			t.Errorf("component 0,%d = %v, want equal red and blue and no green", j, c)
+		}
+	}
+}
+
+func TestColors(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	fill(img, img.Bounds(), color.NRGBA{255, 0, 0, 255})
+	fill(img, image.Rect(0, 0, 4, 1), color.NRGBA{0, 0, 255, 255})
+	average, dominant := Colors(img)
+	if average != "#bf003f" {
+		t.Errorf("average = %s, want #bf003f", average)
+	}
+	if dominant != "#ff0000" {
+		t.Errorf("dominant = %s, want #ff0000", dominant)
+	}
+
+	if average, dominant = Colors(image.NewNRGBA(image.Rect(0, 0, 0, 0))); average != "" || dominant != "" {
+		t.Errorf("empty image colors = %q, %q", average, dominant)
+	}
+}
+
+func TestSetPlaceholders(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 300))
+	fill(img, img.Bounds(), color.NRGBA{0x20, 0x40, 0x60, 255})
+	var m Metadata
+	m.SetPlaceholders(img)
+	if len(m.BlurHash) != 4+2*blurHashX*blurHashY || m.AverageColor != "#204060" || m.DominantColor != "#204060" {
+		t.Errorf("placeholders = %q, %s, %s", m.BlurHash, m.AverageColor, m.DominantColor)
+	}
+}
@@ -0,0 +1,77 @@
+package photo
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+const (
+	LocationExact  = "exact"
+	LocationCoarse = "coarse" // default: GPS rounded to ~1km, location reduced to region and country
+	LocationHidden = "hidden"
+
+	coarsePrecision = 100 // 2 decimal places
+	publicQuality   = 92
+)
+
+// StripMetadata re-encodes the image at name with its EXIF orientation applied and no EXIF, GPS
+// or other metadata, returning a temp file the caller must remove.
+func StripMetadata(name string) (string, error) {
+	src, err := Open(name)
+	if err != nil {
+		return "", err
+	}
+	return StripImage(src)
+}
+
+// StripImage encodes img as a JPEG without metadata, returning a temp file the caller must remove.
+func StripImage(img image.Image) (string, error) {
+	return writeTempJPEG(img, publicQuality)
+}
+
+// Validate checks user-editable fields.
+func (m Metadata) Validate() error {
+	switch m.LocationPrivacy {
+	case "", LocationExact, LocationCoarse, LocationHidden:
+	default:
+		return fmt.Errorf("invalid locationPrivacy: %s", m.LocationPrivacy)
+	}
+	return m.validateDescription()
+}
+
+// Edit returns m with the user-editable fields of edit applied. Everything else, including exact
+// location, renditions and EXIF data, is kept as stored.
+func (m Metadata) Edit(edit Metadata) Metadata {
+	m.Category = edit.Category
+	m.Tags = edit.Tags
+	m.Caption = edit.Caption
+	m.AltText = edit.AltText
+	m.Credit = edit.Credit
+	m.License = edit.License
+	m.LocationPrivacy = edit.LocationPrivacy
+	return m
+}
+
+// Public returns the metadata as served to anonymous callers, with location reduced according to LocationPrivacy.
+func (m Metadata) Public() Metadata {
+	switch m.LocationPrivacy {
+	case LocationExact:
+	case LocationHidden:
+		m.GPSLatitude = 0
+		m.GPSLongitude = 0
+		m.Location = nil
+	default:
+		m.GPSLatitude = math.Round(m.GPSLatitude*coarsePrecision) / coarsePrecision
+		m.GPSLongitude = math.Round(m.GPSLongitude*coarsePrecision) / coarsePrecision
+		if m.Location != nil {
+			m.Location = &Location{
+				Region:      m.Location.Region,
+				RegionCode:  m.Location.RegionCode,
+				Country:     m.Location.Country,
+				CountryCode: m.Location.CountryCode,
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,43 @@
+package photo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEditKeepsStoredFields(t *testing.T) {
+	stored := Metadata{
+		Filename:     "p",
+		GPSLatitude:  45.123456,
+		GPSLongitude: -93.654321,
+		Location:     &Location{Label: "1 Main St", Region: "Minnesota", Country: "United States"},
+		Category:     "live",
+		Uploaded:     time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+		Renditions:   []Rendition{{Name: RenditionThumbnail, Width: 200, Height: 150}},
+		Width:        4000,
+		Height:       3000,
+		Camera:       &Camera{Make: "Canon"},
+		Hash:         "abcd",
+		BlurHash:     "LEHV6nWB2yk8",
+	}
+	// what a client sends back after listing: a Public projection with edits
+	edit := stored.Public()
+	edit.Renditions = nil
+	edit.Camera = nil
+	edit.Hash = ""
+	edit.Category = "studio"
+	edit.Tags = []string{"drums"}
+	edit.Caption = "Soundcheck"
+	edit.LocationPrivacy = LocationHidden
+
+	got := stored.Edit(edit)
+	want := stored
+	want.Category = "studio"
+	want.Tags = []string{"drums"}
+	want.Caption = "Soundcheck"
+	want.LocationPrivacy = LocationHidden
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Edit() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+package photo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SortDate     = "date"
+	SortFilename = "filename"
+	SortUploaded = "uploaded"
+
+	DefaultLimit = 50
+	MaxLimit     = 200
+	NoLimit      = -1 // return every match on one page
+
+	sortTimeFormat = "2006-01-02T15:04:05.000000000Z"
+)
+
+// ListOptions filters, orders and paginates photo metadata. Zero values match everything.
+type ListOptions struct {
+	Sort       string
+	Descending bool
+	Category   string
+	Tag        string
+	Photos     map[string]struct{} // restrict to these filenames, e.g. an album's photos; nil for no restriction
+	From       time.Time           // DateTimeOriginal lower bound, inclusive
+	To         time.Time           // DateTimeOriginal upper bound, exclusive
+	Country    string              // Location.Country or Location.CountryCode
+	Region     string              // Location.Region or Location.RegionCode
+	Cursor     string
+	Limit      int // DefaultLimit if zero, at most MaxLimit, or NoLimit
+}
+
+type cursor struct {
+	Sort     string `json:"s"`
+	Key      string `json:"k"`
+	Filename string `json:"f"`
+}
+
+type keyed struct {
+	key      string
+	metadata Metadata
+}
+
+// List returns one page of matching metadata in a stable order, and the cursor for the next page,
+// which is empty on the last page.
+func List(metadata map[string]Metadata, opts ListOptions) ([]Metadata, string, error) {
+	if opts.Sort == "" {
+		opts.Sort = SortDate
+	}
+	switch {
+	case opts.Limit == NoLimit:
+	case opts.Limit <= 0:
+		opts.Limit = DefaultLimit
+	case opts.Limit > MaxLimit:
+		opts.Limit = MaxLimit
+	}
+	var after *cursor
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if c.Sort != opts.Sort {
+			return nil, "", fmt.Errorf("cursor does not match sort")
+		}
+		after = c
+	}
+
+	var items []keyed
+	for _, m := range metadata {
+		if !opts.matches(m) {
+			continue
+		}
+		key, err := sortKey(m, opts.Sort)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, keyed{key: key, metadata: m})
+	}
+	less := func(aKey, aName, bKey, bName string) bool {
+		if aKey == bKey {
+			aKey, bKey = aName, bName
+		}
+		if opts.Descending {
+			return aKey > bKey
+		}
+		return aKey < bKey
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return less(items[i].key, items[i].metadata.Filename, items[j].key, items[j].metadata.Filename)
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(items), func(i int) bool {
+			return less(after.Key, after.Filename, items[i].key, items[i].metadata.Filename)
+		})
+	}
+	end := start + opts.Limit
+	if opts.Limit == NoLimit || end > len(items) {
+		end = len(items)
+	}
+	page := make([]Metadata, 0, end-start)
+	for _, item := range items[start:end] {
+		page = append(page, item.metadata)
+	}
+	var next string
+	if end < len(items) {
+		last := items[end-1]
+		next = encodeCursor(cursor{Sort: opts.Sort, Key: last.key, Filename: last.metadata.Filename})
+	}
+	return page, next, nil
+}
+
+func (opts ListOptions) matches(m Metadata) bool {
+	if m.IsDeleted() {
+		return false
+	}
+	if opts.Category != "" && !strings.EqualFold(m.Category, opts.Category) {
+		return false
+	}
+	if opts.Tag != "" && !hasTag(m.Tags, opts.Tag) {
+		return false
+	}
+	if opts.Photos != nil {
+		if _, ok := opts.Photos[m.Filename]; !ok {
+			return false
+		}
+	}
+	if !opts.From.IsZero() && m.DateTimeOriginal.Before(opts.From) {
+		return false
+	}
+	if !opts.To.IsZero() && !m.DateTimeOriginal.Before(opts.To) {
+		return false
+	}
+	if opts.Country != "" && (m.Location == nil || !(strings.EqualFold(m.Location.Country, opts.Country) || strings.EqualFold(m.Location.CountryCode, opts.Country))) {
+		return false
+	}
+	if opts.Region != "" && (m.Location == nil || !(strings.EqualFold(m.Location.Region, opts.Region) || strings.EqualFold(m.Location.RegionCode, opts.Region))) {
+		return false
+	}
+	return true
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortKey(m Metadata, field string) (string, error) {
+	switch field {
+	case SortDate:
+		return m.DateTimeOriginal.UTC().Format(sortTimeFormat), nil
+	case SortUploaded:
+		return m.Uploaded.UTC().Format(sortTimeFormat), nil
+	case SortFilename:
+		return strings.ToLower(m.Filename), nil
+	default:
+		return "", fmt.Errorf("invalid sort: %s", field)
+	}
+}
+
+func encodeCursor(c cursor) string {
+	j, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(j)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	j, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c cursor
+	if err = json.Unmarshal(j, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
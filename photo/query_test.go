@@ -0,0 +1,112 @@
+package photo
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestListCursor(t *testing.T) {
+	metadata := make(map[string]Metadata)
+	day := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		name := fmt.Sprintf("p%d", i)
+		metadata[name] = Metadata{
+			Filename:         name,
+			DateTimeOriginal: day.AddDate(0, 0, i/2), // pairs share a date, ordered by filename
+			Category:         []string{"live", "studio"}[i%2],
+		}
+	}
+	metadata["trashed"] = Metadata{Filename: "trashed", Deleted: day}
+
+	tests := []struct {
+		name  string
+		opts  ListOptions
+		pages [][]string
+	}{
+		{
+			name:  "date ascending",
+			opts:  ListOptions{Limit: 3},
+			pages: [][]string{{"p0", "p1", "p2"}, {"p3", "p4", "p5"}, {"p6"}},
+		},
+		{
+			name:  "date descending",
+			opts:  ListOptions{Limit: 3, Descending: true},
+			pages: [][]string{{"p6", "p5", "p4"}, {"p3", "p2", "p1"}, {"p0"}},
+		},
+		{
+			name:  "exact pages",
+			opts:  ListOptions{Sort: SortFilename, Limit: 7},
+			pages: [][]string{{"p0", "p1", "p2", "p3", "p4", "p5", "p6"}},
+		},
+		{
+			name:  "filtered",
+			opts:  ListOptions{Category: "studio", Limit: 2},
+			pages: [][]string{{"p1", "p3"}, {"p5"}},
+		},
+		{
+			name:  "no limit",
+			opts:  ListOptions{Limit: NoLimit},
+			pages: [][]string{{"p0", "p1", "p2", "p3", "p4", "p5", "p6"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			var pages [][]string
+			for {
+				page, next, err := List(metadata, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var names []string
+				for _, m := range page {
+					names = append(names, m.Filename)
+				}
+				pages = append(pages, names)
+				if next == "" {
+					break
+				}
+				if len(pages) > len(tt.pages) {
+					t.Fatalf("too many pages: %v", pages)
+				}
+				opts.Cursor = next
+			}
+			if !reflect.DeepEqual(pages, tt.pages) {
+				t.Errorf("pages = %v, want %v", pages, tt.pages)
+			}
+		})
+	}
+}
+
+func TestListInvalidCursor(t *testing.T) {
+	metadata := map[string]Metadata{"a": {Filename: "a"}, "b": {Filename: "b"}}
+	_, next, err := List(metadata, ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = List(metadata, ListOptions{Sort: SortFilename, Cursor: next}); err == nil {
+		t.Error("expected error for a cursor from another sort")
+	}
+	if _, _, err = List(metadata, ListOptions{Cursor: "not a cursor"}); err == nil {
+		t.Error("expected error for an invalid cursor")
+	}
+}
+
+func TestListLimit(t *testing.T) {
+	metadata := make(map[string]Metadata)
+	for i := 0; i < MaxLimit+10; i++ {
+		name := fmt.Sprintf("p%03d", i)
+		metadata[name] = Metadata{Filename: name}
+	}
+	for _, tt := range []struct{ limit, want int }{{0, DefaultLimit}, {MaxLimit + 1, MaxLimit}, {NoLimit, MaxLimit + 10}} {
+		page, _, err := List(metadata, ListOptions{Limit: tt.limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != tt.want {
+			t.Errorf("limit %d: got %d photos, want %d", tt.limit, len(page), tt.want)
+		}
+	}
+}
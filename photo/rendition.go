@@ -0,0 +1,122 @@
+package photo
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	RenditionThumbnail = "thumb"
+	thumbnailSize      = 100
+	renditionQuality   = 85
+)
+
+// RenditionSpec describes a derived image. Square renditions are center-cropped to Width x Width;
+// others are scaled to Width, preserving aspect ratio, and skipped if the source is narrower.
+type RenditionSpec struct {
+	Width  int
+	Square bool
+}
+
+// Renditions are generated for every uploaded photo.
+var Renditions = []RenditionSpec{
+	{Width: thumbnailSize, Square: true},
+	{Width: 320},
+	{Width: 800},
+	{Width: 1600},
+}
+
+func (s RenditionSpec) Name() string {
+	if s.Square {
+		return RenditionThumbnail
+	}
+	return fmt.Sprintf("w%d", s.Width)
+}
+
+// Rendition is a generated image. File is the local temp file and is not persisted.
+type Rendition struct {
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	File   string `json:"-"`
+}
+
+// CreateRenditions decodes the image at name, applying its EXIF orientation, and writes each spec
+// to a temp file. Callers must remove the files.
+func CreateRenditions(name string, specs []RenditionSpec) ([]Rendition, error) {
+	src, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return RenditionsFromImage(src, specs)
+}
+
+// Open decodes the image at name, applying its EXIF orientation.
+func Open(name string) (image.Image, error) {
+	return imaging.Open(name, imaging.AutoOrientation(true))
+}
+
+// Decode decodes an image from r, applying its EXIF orientation.
+func Decode(r io.Reader) (image.Image, error) {
+	return imaging.Decode(r, imaging.AutoOrientation(true))
+}
+
+func RenditionsFromImage(src image.Image, specs []RenditionSpec) ([]Rendition, error) {
+	return WatermarkedRenditions(src, specs, nil)
+}
+
+// WatermarkedRenditions is RenditionsFromImage with watermark applied to every rendition but the
+// square thumbnails, which are too small for a legible mark and would crop it off.
+func WatermarkedRenditions(src image.Image, specs []RenditionSpec, watermark *Watermark) ([]Rendition, error) {
+	marked := watermark.Apply(src)
+	var renditions []Rendition
+	for _, spec := range specs {
+		var dst image.Image
+		switch {
+		case spec.Square:
+			dst = imaging.Thumbnail(src, spec.Width, spec.Width, imaging.CatmullRom)
+		case src.Bounds().Dx() < spec.Width:
+			continue
+		default:
+			dst = imaging.Resize(marked, spec.Width, 0, imaging.Lanczos)
+		}
+		file, err := writeTempJPEG(dst, renditionQuality)
+		if err != nil {
+			RemoveRenditions(renditions)
+			return nil, err
+		}
+		renditions = append(renditions, Rendition{
+			Name:   spec.Name(),
+			Width:  dst.Bounds().Dx(),
+			Height: dst.Bounds().Dy(),
+			File:   file,
+		})
+	}
+	return renditions, nil
+}
+
+// RemoveRenditions deletes the temp files of renditions.
+func RemoveRenditions(renditions []Rendition) {
+	for _, r := range renditions {
+		if r.File != "" {
+			os.Remove(r.File)
+		}
+	}
+}
+
+func writeTempJPEG(img image.Image, quality int) (string, error) {
+	tmp, err := os.CreateTemp("", "photo.*.jpeg")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+	if err = imaging.Encode(tmp, img, imaging.JPEG, imaging.JPEGQuality(quality)); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,275 @@
+package photo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	searchIndexVersion = 2
+
+	FacetCategory = "category"
+	FacetTags     = "tags"
+	FacetYear     = "year"
+	FacetCountry  = "country"
+)
+
+// field weights: a match on a tag or category ranks above one on a filename
+var searchWeights = struct {
+	filename, location, caption, tag, category int
+}{1, 2, 2, 3, 3}
+
+// SearchIndex is an inverted index over the public projection of photo metadata, so hidden
+// locations are not searchable.
+type SearchIndex struct {
+	Version  int                       `json:"version"`
+	Source   string                    `json:"source"`   // Fingerprint of the metadata it was built from
+	Postings map[string]map[string]int `json:"postings"` // term -> photo -> weight
+	Facets   map[string]Facets         `json:"facets"`   // photo -> facet values
+
+	terms []string // sorted keys of Postings, for prefix matching
+}
+
+// Facets are the values of a photo counted by search facets.
+type Facets struct {
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Year     string   `json:"year,omitempty"`
+	Country  string   `json:"country,omitempty"`
+	Date     string   `json:"date,omitempty"` // tie-breaker, newest first
+}
+
+// SearchOptions are a free text query and facet filters. Zero values match everything.
+type SearchOptions struct {
+	Query    string
+	Category string
+	Tag      string
+	Year     string
+	Country  string
+	Offset   int
+	Limit    int
+}
+
+// SearchResult is one page of matching photos, best first, with facet counts over all matches.
+type SearchResult struct {
+	Photos []string                  `json:"photos"`
+	Total  int                       `json:"total"`
+	Facets map[string]map[string]int `json:"facets"`
+}
+
+// Fingerprint identifies a version of the photo metadata, to tell whether an index is stale.
+func Fingerprint(metadata map[string]Metadata) (string, error) {
+	j, err := json.Marshal(metadata) // map keys are sorted, so this is deterministic
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(j)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Fresh reports whether the index was built by this version of the code from metadata with
+// fingerprint source.
+func (idx *SearchIndex) Fresh(source string) bool {
+	return idx.Version == searchIndexVersion && idx.Source == source
+}
+
+func BuildSearchIndex(metadata map[string]Metadata) (*SearchIndex, error) {
+	source, err := Fingerprint(metadata)
+	if err != nil {
+		return nil, err
+	}
+	idx := &SearchIndex{
+		Version:  searchIndexVersion,
+		Source:   source,
+		Postings: make(map[string]map[string]int),
+		Facets:   make(map[string]Facets),
+	}
+	for key, m := range metadata {
+		if m.IsDeleted() {
+			continue
+		}
+		m = m.Public()
+		idx.add(key, searchWeights.filename, key, m.Filename)
+		idx.add(key, searchWeights.caption, m.Caption, m.AltText, m.Credit)
+		idx.add(key, searchWeights.category, m.Category)
+		idx.add(key, searchWeights.tag, m.Tags...)
+		// tags and categories are entered by hand; count "Gig" and "gig" together
+		facets := Facets{
+			Category: strings.ToLower(m.Category),
+		}
+		for _, tag := range m.Tags {
+			facets.Tags = append(facets.Tags, strings.ToLower(tag))
+		}
+		if m.Location != nil {
+			idx.add(key, searchWeights.location, m.Location.Label, m.Location.Name, m.Location.Region, m.Location.RegionCode, m.Location.Country, m.Location.CountryCode)
+			facets.Country = m.Location.Country
+		}
+		if !m.DateTimeOriginal.IsZero() {
+			facets.Year = strconv.Itoa(m.DateTimeOriginal.Year())
+			facets.Date = m.DateTimeOriginal.UTC().Format(sortTimeFormat)
+		}
+		idx.Facets[key] = facets
+	}
+	idx.sortTerms()
+	return idx, nil
+}
+
+// ReadSearchIndex decodes an index written as JSON.
+func ReadSearchIndex(r io.Reader) (*SearchIndex, error) {
+	var idx SearchIndex
+	if err := json.NewDecoder(r).Decode(&idx); err != nil {
+		return nil, err
+	}
+	idx.sortTerms()
+	return &idx, nil
+}
+
+func (idx *SearchIndex) sortTerms() {
+	idx.terms = make([]string, 0, len(idx.Postings))
+	for term := range idx.Postings {
+		idx.terms = append(idx.terms, term)
+	}
+	sort.Strings(idx.terms)
+}
+
+func (idx *SearchIndex) add(key string, weight int, texts ...string) {
+	for _, text := range texts {
+		for _, term := range tokenize(text) {
+			postings, ok := idx.Postings[term]
+			if !ok {
+				postings = make(map[string]int)
+				idx.Postings[term] = postings
+			}
+			if weight > postings[key] {
+				postings[key] = weight
+			}
+		}
+	}
+}
+
+// tokenize lowercases text and splits it into letter and digit runs.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Search returns photos matching every query term, each as a prefix of an indexed term, and the
+// facet filters.
+func (idx *SearchIndex) Search(opts SearchOptions) SearchResult {
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultLimit
+	}
+	if opts.Limit > MaxLimit {
+		opts.Limit = MaxLimit
+	}
+
+	// score candidates: the best weight of each query term, summed
+	var scores map[string]int
+	if terms := tokenize(opts.Query); len(terms) > 0 {
+		for _, term := range terms {
+			matches := idx.match(term)
+			if scores == nil {
+				scores = matches
+				continue
+			}
+			for key := range scores {
+				if weight, ok := matches[key]; ok {
+					scores[key] += weight
+				} else {
+					delete(scores, key)
+				}
+			}
+		}
+	} else {
+		scores = make(map[string]int, len(idx.Facets))
+		for key := range idx.Facets {
+			scores[key] = 0
+		}
+	}
+
+	result := SearchResult{
+		Photos: []string{},
+		Facets: map[string]map[string]int{
+			FacetCategory: {},
+			FacetTags:     {},
+			FacetYear:     {},
+			FacetCountry:  {},
+		},
+	}
+	var keys []string
+	for key := range scores {
+		facets := idx.Facets[key]
+		if !facets.matches(opts) {
+			continue
+		}
+		keys = append(keys, key)
+		count(result.Facets[FacetCategory], facets.Category)
+		count(result.Facets[FacetYear], facets.Year)
+		count(result.Facets[FacetCountry], facets.Country)
+		for _, tag := range facets.Tags {
+			count(result.Facets[FacetTags], tag)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		if idx.Facets[a].Date != idx.Facets[b].Date {
+			return idx.Facets[a].Date > idx.Facets[b].Date
+		}
+		return a < b
+	})
+
+	result.Total = len(keys)
+	if opts.Offset < len(keys) {
+		end := opts.Offset + opts.Limit
+		if end > len(keys) {
+			end = len(keys)
+		}
+		result.Photos = append(result.Photos, keys[opts.Offset:end]...)
+	}
+	return result
+}
+
+// match returns the best weight per photo of indexed terms beginning with prefix.
+func (idx *SearchIndex) match(prefix string) map[string]int {
+	matches := make(map[string]int)
+	for i := sort.SearchStrings(idx.terms, prefix); i < len(idx.terms) && strings.HasPrefix(idx.terms[i], prefix); i++ {
+		for key, weight := range idx.Postings[idx.terms[i]] {
+			if weight > matches[key] {
+				matches[key] = weight
+			}
+		}
+	}
+	return matches
+}
+
+func (f Facets) matches(opts SearchOptions) bool {
+	if opts.Category != "" && !strings.EqualFold(f.Category, opts.Category) {
+		return false
+	}
+	if opts.Tag != "" && !hasTag(f.Tags, opts.Tag) {
+		return false
+	}
+	if opts.Year != "" && f.Year != opts.Year {
+		return false
+	}
+	if opts.Country != "" && !strings.EqualFold(f.Country, opts.Country) {
+		return false
+	}
+	return true
+}
+
+func count(counts map[string]int, value string) {
+	if value != "" {
+		counts[value]++
+	}
+}
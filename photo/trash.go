@@ -0,0 +1,102 @@
+package photo
+
+import (
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+// DefaultRetention is how long deleted photos stay in the trash before they are purged.
+const DefaultRetention = time.Hour * 24 * 30
+
+// Object is a stored object of a photo.
+type Object struct {
+	Bucket string
+	Key    string
+	Public bool
+}
+
+// Trash is where the object is kept while its photo is deleted.
+func (o Object) Trash() Object {
+	return Object{
+		Bucket: storage.BUCKET_ORIGINALS,
+		Key:    storage.KEY_TRASH_PREFIX + o.Bucket + "/" + o.Key,
+	}
+}
+
+// RenditionObject returns the bucket and key of a photo's rendition. Thumbnails keep their
+// original location in the thumbnails bucket; other renditions are stored as <name>/<id>.
+func RenditionObject(id, name string) (string, string) {
+	if name == RenditionThumbnail {
+		return storage.BUCKET_THUMBNAILS, id
+	}
+	return storage.BUCKET_RENDITIONS, name + "/" + id
+}
+
+// Objects returns every object that may be stored for photo id: the public image, the private
+// original and its renditions. Photos from before renditions were recorded have a thumbnail.
+func Objects(id string, m Metadata) []Object {
+	objects := []Object{
+		{Bucket: storage.BUCKET_IMAGES, Key: id, Public: true},
+		{Bucket: storage.BUCKET_ORIGINALS, Key: id},
+	}
+	renditions := m.Renditions
+	if len(renditions) == 0 {
+		renditions = []Rendition{{Name: RenditionThumbnail}}
+	}
+	for _, rendition := range renditions {
+		bucket, key := RenditionObject(id, rendition.Name)
+		objects = append(objects, Object{Bucket: bucket, Key: key, Public: true})
+	}
+	return objects
+}
+
+// IsDeleted reports whether the photo is in the trash.
+func (m Metadata) IsDeleted() bool {
+	return !m.Deleted.IsZero()
+}
+
+// MoveToTrash moves every object of photo id privately to the trash. Objects that don't exist are
+// skipped, so it can be re-run after a partial failure.
+func MoveToTrash(store storage.Storage, id string, m Metadata) error {
+	for _, o := range Objects(id, m) {
+		trash := o.Trash()
+		if err := store.CopyPrivate(o.Bucket, o.Key, trash.Bucket, trash.Key); err != nil {
+			return err
+		}
+		if err := store.Delete(o.Bucket, o.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreFromTrash moves every object of photo id from the trash back to where it was, with its
+// original visibility.
+func RestoreFromTrash(store storage.Storage, id string, m Metadata) error {
+	for _, o := range Objects(id, m) {
+		trash := o.Trash()
+		copyObject := store.CopyPrivate
+		if o.Public {
+			copyObject = store.Copy
+		}
+		if err := copyObject(trash.Bucket, trash.Key, o.Bucket, o.Key); err != nil {
+			return err
+		}
+		if err := store.Delete(trash.Bucket, trash.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeTrash permanently deletes the trashed objects of photo id.
+func PurgeTrash(store storage.Storage, id string, m Metadata) error {
+	for _, o := range Objects(id, m) {
+		trash := o.Trash()
+		if err := store.Delete(trash.Bucket, trash.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package photo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+func TestTrash(t *testing.T) {
+	tests := []struct {
+		name    string
+		datum   Metadata
+		objects []Object
+	}{
+		{
+			name:  "renditions",
+			datum: Metadata{Renditions: []Rendition{{Name: RenditionThumbnail}, {Name: "large"}}},
+			objects: []Object{
+				{Bucket: storage.BUCKET_IMAGES, Key: "p"},
+				{Bucket: storage.BUCKET_ORIGINALS, Key: "p"},
+				{Bucket: storage.BUCKET_THUMBNAILS, Key: "p"},
+				{Bucket: storage.BUCKET_RENDITIONS, Key: "large/p"},
+			},
+		},
+		{
+			name:  "before renditions were recorded",
+			datum: Metadata{},
+			objects: []Object{
+				{Bucket: storage.BUCKET_IMAGES, Key: "p"},
+				{Bucket: storage.BUCKET_THUMBNAILS, Key: "p"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := storage.NewLocal(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, o := range tt.objects {
+				if err = store.Write(o.Bucket, o.Key, o.Bucket+"/"+o.Key); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err = MoveToTrash(store, "p", tt.datum); err != nil {
+				t.Fatal(err)
+			}
+			for _, o := range tt.objects {
+				if contents(t, store, o) != "" {
+					t.Errorf("%s/%s not moved", o.Bucket, o.Key)
+				}
+				if got := contents(t, store, o.Trash()); got != o.Bucket+"/"+o.Key {
+					t.Errorf("trash of %s/%s = %q", o.Bucket, o.Key, got)
+				}
+			}
+			// re-running after a partial failure skips what's already moved
+			if err = MoveToTrash(store, "p", tt.datum); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = RestoreFromTrash(store, "p", tt.datum); err != nil {
+				t.Fatal(err)
+			}
+			for _, o := range tt.objects {
+				if got := contents(t, store, o); got != o.Bucket+"/"+o.Key {
+					t.Errorf("restored %s/%s = %q", o.Bucket, o.Key, got)
+				}
+				if contents(t, store, o.Trash()) != "" {
+					t.Errorf("%s/%s still in the trash", o.Bucket, o.Key)
+				}
+			}
+
+			if err = MoveToTrash(store, "p", tt.datum); err != nil {
+				t.Fatal(err)
+			}
+			if err = PurgeTrash(store, "p", tt.datum); err != nil {
+				t.Fatal(err)
+			}
+			keys, err := store.ListPrefix(storage.BUCKET_ORIGINALS, storage.KEY_TRASH_PREFIX)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != 0 {
+				t.Errorf("trash after purge = %v", keys)
+			}
+		})
+	}
+}
+
+// contents returns the string stored at o, or "" if there is none.
+func contents(t *testing.T, store storage.Storage, o Object) string {
+	t.Helper()
+	r, err := store.Get(o.Bucket, o.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var s string
+	json.NewDecoder(r).Decode(&s)
+	return s
+}
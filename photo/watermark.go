@@ -0,0 +1,256 @@
+package photo
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+const (
+	PositionTopLeft     = "top-left"
+	PositionTopRight    = "top-right"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottomRight = "bottom-right"
+	PositionCenter      = "center"
+
+	DefaultWatermarkOpacity = 0.5
+	DefaultWatermarkScale   = 0.2
+	watermarkMargin         = 0.02 // of the shorter side
+	watermarkTextSize       = 96   // points; the mark is scaled to the image anyway
+)
+
+// Watermark composites a mark, a logo or rendered text, onto public images.
+type Watermark struct {
+	Mark     image.Image
+	Position string
+	Opacity  float64 // 0-1
+	Scale    float64 // mark width relative to image width, 0-1
+}
+
+// NewTextWatermark returns a watermark of text in white with a dark outline, legible on light
+// and dark photos alike.
+func NewTextWatermark(text string) (*Watermark, error) {
+	mark, err := renderText(text)
+	if err != nil {
+		return nil, err
+	}
+	return NewWatermark(mark), nil
+}
+
+// NewWatermark returns a watermark of mark with the default position, opacity and scale.
+func NewWatermark(mark image.Image) *Watermark {
+	return &Watermark{
+		Mark:     mark,
+		Position: PositionBottomRight,
+		Opacity:  DefaultWatermarkOpacity,
+		Scale:    DefaultWatermarkScale,
+	}
+}
+
+func (w *Watermark) Validate() error {
+	switch w.Position {
+	case PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight, PositionCenter:
+	default:
+		return fmt.Errorf("invalid watermark position: %s", w.Position)
+	}
+	if w.Opacity <= 0 || w.Opacity > 1 {
+		return fmt.Errorf("invalid watermark opacity: %v", w.Opacity)
+	}
+	if w.Scale <= 0 || w.Scale > 1 {
+		return fmt.Errorf("invalid watermark scale: %v", w.Scale)
+	}
+	if w.Mark == nil {
+		return fmt.Errorf("watermark has no mark")
+	}
+	return nil
+}
+
+// Apply returns a copy of img with the watermark composited onto it. A nil Watermark returns img.
+func (w *Watermark) Apply(img image.Image) image.Image {
+	if w == nil {
+		return img
+	}
+	bounds := img.Bounds()
+	width := int(math.Round(float64(bounds.Dx()) * w.Scale))
+	if width < 1 {
+		return img
+	}
+	mark := imaging.Resize(w.Mark, width, 0, imaging.Lanczos)
+	margin := int(float64(minInt(bounds.Dx(), bounds.Dy())) * watermarkMargin)
+
+	left, top := bounds.Min.X+margin, bounds.Min.Y+margin
+	right, bottom := bounds.Max.X-margin-mark.Bounds().Dx(), bounds.Max.Y-margin-mark.Bounds().Dy()
+	var pos image.Point
+	switch w.Position {
+	case PositionTopLeft:
+		pos = image.Pt(left, top)
+	case PositionTopRight:
+		pos = image.Pt(right, top)
+	case PositionBottomLeft:
+		pos = image.Pt(left, bottom)
+	case PositionCenter:
+		pos = image.Pt((bounds.Min.X+bounds.Max.X-mark.Bounds().Dx())/2, (bounds.Min.Y+bounds.Max.Y-mark.Bounds().Dy())/2)
+	default:
+		pos = image.Pt(right, bottom)
+	}
+	return imaging.Overlay(img, mark, pos, w.Opacity)
+}
+
+// WatermarkFromEnv returns the watermark configured by WATERMARK_LOGO, a key of an image in the api
+// bucket, or WATERMARK_TEXT, with WATERMARK_POSITION, WATERMARK_OPACITY and WATERMARK_SCALE
+// overriding the defaults. It returns nil if neither a logo nor text is set.
+func WatermarkFromEnv(store storage.Storage) (*Watermark, error) {
+	var watermark *Watermark
+	if key := os.Getenv("WATERMARK_LOGO"); key != "" {
+		// a missing key reads as an empty body, which would be reported as an unknown image format
+		ok, err := store.Exists(storage.BUCKET_API, key)
+		if err != nil {
+			return nil, fmt.Errorf("watermark logo: %w", err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("watermark logo: logo not found: %s", key)
+		}
+		r, err := store.Get(storage.BUCKET_API, key)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		logo, err := Decode(r)
+		if err != nil {
+			return nil, fmt.Errorf("watermark logo: %w", err)
+		}
+		watermark = NewWatermark(logo)
+	} else if text := os.Getenv("WATERMARK_TEXT"); text != "" {
+		var err error
+		if watermark, err = NewTextWatermark(text); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, nil
+	}
+
+	if position := os.Getenv("WATERMARK_POSITION"); position != "" {
+		watermark.Position = position
+	}
+	if opacity, err := strconv.ParseFloat(os.Getenv("WATERMARK_OPACITY"), 64); err == nil {
+		watermark.Opacity = opacity
+	}
+	if scale, err := strconv.ParseFloat(os.Getenv("WATERMARK_SCALE"), 64); err == nil {
+		watermark.Scale = scale
+	}
+	return watermark, watermark.Validate()
+}
+
+// renderText draws text onto a transparent image just large enough to hold it.
+func renderText(text string) (image.Image, error) {
+	if text == "" {
+		return nil, fmt.Errorf("watermark text is empty")
+	}
+	f, err := sfnt.Parse(gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		buf  sfnt.Buffer
+		ppem = fixed.I(watermarkTextSize)
+	)
+	metrics, err := f.Metrics(&buf, ppem, font.HintingNone)
+	if err != nil {
+		return nil, err
+	}
+
+	// lay out glyphs along the baseline
+	type glyph struct {
+		index sfnt.GlyphIndex
+		x     fixed.Int26_6
+	}
+	var (
+		glyphs []glyph
+		x      fixed.Int26_6
+		prev   sfnt.GlyphIndex
+	)
+	for i, r := range text {
+		index, err := f.GlyphIndex(&buf, r)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			if kern, err := f.Kern(&buf, prev, index, ppem, font.HintingNone); err == nil {
+				x += kern
+			}
+		}
+		glyphs = append(glyphs, glyph{index: index, x: x})
+		advance, err := f.GlyphAdvance(&buf, index, ppem, font.HintingNone)
+		if err != nil {
+			return nil, err
+		}
+		x += advance
+		prev = index
+	}
+
+	outline := watermarkTextSize / 24
+	width := x.Ceil() + 2*outline
+	height := (metrics.Ascent + metrics.Descent).Ceil() + 2*outline
+	baseline := float32(outline + metrics.Ascent.Ceil())
+	rasterizer := vector.NewRasterizer(width, height)
+	for _, g := range glyphs {
+		segments, err := f.LoadGlyph(&buf, g.index, ppem, nil)
+		if err != nil {
+			return nil, err
+		}
+		originX := float32(outline) + float32(g.x)/64
+		point := func(p fixed.Point26_6) (float32, float32) {
+			return originX + float32(p.X)/64, baseline + float32(p.Y)/64
+		}
+		for _, segment := range segments {
+			switch segment.Op {
+			case sfnt.SegmentOpMoveTo:
+				rasterizer.MoveTo(point(segment.Args[0]))
+			case sfnt.SegmentOpLineTo:
+				rasterizer.LineTo(point(segment.Args[0]))
+			case sfnt.SegmentOpQuadTo:
+				bx, by := point(segment.Args[0])
+				cx, cy := point(segment.Args[1])
+				rasterizer.QuadTo(bx, by, cx, cy)
+			case sfnt.SegmentOpCubeTo:
+				bx, by := point(segment.Args[0])
+				cx, cy := point(segment.Args[1])
+				dx, dy := point(segment.Args[2])
+				rasterizer.CubeTo(bx, by, cx, cy, dx, dy)
+			}
+		}
+		rasterizer.ClosePath()
+	}
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	rasterizer.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+
+	// outline by drawing the text offset in every direction, then the text itself on top
+	dst := image.NewNRGBA(mask.Bounds())
+	shadow := image.NewUniform(color.NRGBA{0, 0, 0, 160})
+	for dy := -outline; dy <= outline; dy += outline {
+		for dx := -outline; dx <= outline; dx += outline {
+			draw.DrawMask(dst, dst.Bounds(), shadow, image.Point{}, mask, image.Pt(dx, dy), draw.Over)
+		}
+	}
+	draw.DrawMask(dst, dst.Bounds(), image.White, image.Point{}, mask, image.Point{}, draw.Over)
+	return dst, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
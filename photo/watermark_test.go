@@ -0,0 +1,51 @@
+package photo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+func TestWatermarkFromEnv(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Write(storage.BUCKET_API, "not-an-image", "text"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		mark bool
+		err  string
+	}{
+		{name: "none"},
+		{name: "text", env: map[string]string{"WATERMARK_TEXT": "CE"}, mark: true},
+		{name: "missing logo", env: map[string]string{"WATERMARK_LOGO": "missing.png"}, err: "logo not found"},
+		{name: "unreadable logo", env: map[string]string{"WATERMARK_LOGO": "not-an-image"}, err: "watermark logo"},
+		{name: "invalid position", env: map[string]string{"WATERMARK_TEXT": "CE", "WATERMARK_POSITION": "middle"}, err: "invalid watermark position"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"WATERMARK_LOGO", "WATERMARK_TEXT", "WATERMARK_POSITION", "WATERMARK_OPACITY", "WATERMARK_SCALE"} {
+				t.Setenv(name, tt.env[name])
+			}
+			watermark, err := WatermarkFromEnv(store)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (watermark != nil) != tt.mark {
+				t.Errorf("watermark = %v, want mark %v", watermark, tt.mark)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/jobs"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+// Report lists the photos whose objects and photos.json disagree, by photo ID.
+type Report struct {
+	ImagesWithoutThumbnails []string `json:"imagesWithoutThumbnails"`
+	ThumbnailsWithoutImages []string `json:"thumbnailsWithoutImages"`
+	OriginalsWithoutImages  []string `json:"originalsWithoutImages"`
+	MetadataWithoutObjects  []string `json:"metadataWithoutObjects"` // neither a public image nor an original
+	ObjectsWithoutMetadata  []string `json:"objectsWithoutMetadata"` // not in photos.json at all
+	DeletedWithObjects      []string `json:"deletedWithObjects"`     // in the trash, but objects weren't moved
+	// InProgress are objects without metadata that belong to unfinished upload jobs. They aren't
+	// problems, and Fix leaves them alone.
+	InProgress []string `json:"inProgress"`
+
+	metadata map[string]photo.Metadata
+	photos   map[string]*objects
+}
+
+// objects records which objects exist for a photo.
+type objects struct {
+	image      bool
+	thumbnail  bool
+	original   bool
+	renditions []string // names, other than the thumbnail
+}
+
+func (o *objects) any() bool {
+	return o.image || o.thumbnail || o.original || len(o.renditions) > 0
+}
+
+// OK reports whether no problems were found.
+func (r *Report) OK() bool {
+	return len(r.ImagesWithoutThumbnails)+len(r.ThumbnailsWithoutImages)+len(r.OriginalsWithoutImages)+
+		len(r.MetadataWithoutObjects)+len(r.ObjectsWithoutMetadata)+len(r.DeletedWithObjects) == 0
+}
+
+// Check lists every photo bucket and compares it with photos.json.
+func Check(store storage.Storage) (*Report, error) {
+	report := &Report{
+		ImagesWithoutThumbnails: []string{},
+		ThumbnailsWithoutImages: []string{},
+		OriginalsWithoutImages:  []string{},
+		MetadataWithoutObjects:  []string{},
+		ObjectsWithoutMetadata:  []string{},
+		DeletedWithObjects:      []string{},
+		InProgress:              []string{},
+		photos:                  make(map[string]*objects),
+	}
+	var err error
+	if report.metadata, err = readMetadata(store); err != nil {
+		return nil, err
+	}
+	unfinished, err := jobs.NewStore(store).Unfinished()
+	if err != nil {
+		return nil, err
+	}
+	get := func(id string) *objects {
+		o, ok := report.photos[id]
+		if !ok {
+			o = &objects{}
+			report.photos[id] = o
+		}
+		return o
+	}
+
+	keys, err := store.List(storage.BUCKET_IMAGES)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		get(key).image = true
+	}
+	if keys, err = store.List(storage.BUCKET_THUMBNAILS); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		get(key).thumbnail = true
+	}
+	if keys, err = store.List(storage.BUCKET_ORIGINALS); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if strings.HasPrefix(key, storage.KEY_TRASH_PREFIX) {
+			continue
+		}
+		get(key).original = true
+	}
+	if keys, err = store.List(storage.BUCKET_RENDITIONS); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		name, id, ok := strings.Cut(key, "/")
+		if !ok {
+			continue
+		}
+		o := get(id)
+		o.renditions = append(o.renditions, name)
+	}
+	for id := range report.metadata {
+		get(id)
+	}
+
+	for id, o := range report.photos {
+		m, ok := report.metadata[id]
+		switch {
+		case !ok:
+			if _, ok := unfinished[id]; ok {
+				report.InProgress = append(report.InProgress, id)
+				delete(report.photos, id)
+				continue
+			}
+			report.ObjectsWithoutMetadata = append(report.ObjectsWithoutMetadata, id)
+			continue
+		case m.IsDeleted():
+			if o.any() {
+				report.DeletedWithObjects = append(report.DeletedWithObjects, id)
+			}
+			continue
+		}
+		if o.image && !o.thumbnail {
+			report.ImagesWithoutThumbnails = append(report.ImagesWithoutThumbnails, id)
+		}
+		if o.thumbnail && !o.image {
+			report.ThumbnailsWithoutImages = append(report.ThumbnailsWithoutImages, id)
+		}
+		if o.original && !o.image {
+			report.OriginalsWithoutImages = append(report.OriginalsWithoutImages, id)
+		}
+		if !o.image && !o.original {
+			report.MetadataWithoutObjects = append(report.MetadataWithoutObjects, id)
+		}
+	}
+	for _, ids := range [][]string{report.ImagesWithoutThumbnails, report.ThumbnailsWithoutImages,
+		report.OriginalsWithoutImages, report.MetadataWithoutObjects, report.ObjectsWithoutMetadata, report.DeletedWithObjects, report.InProgress} {
+		sort.Strings(ids)
+	}
+	return report, nil
+}
+
+// Fix repairs what can be regenerated and moves what can't to the trash, where it can be restored
+// or purged like any deleted photo:
+//   - a missing public image is regenerated from the original, and a missing thumbnail from the
+//     original or the public image
+//   - metadata with no image to regenerate from, and objects without metadata, are trashed
+//   - objects of deleted photos are moved to the trash
+//
+// Objects without metadata are left alone if their photo was saved, or its upload job started,
+// since Check. Only the Deleted field of trashed photos is written back to photos.json, so edits
+// made in the meantime are kept.
+//
+// watermark, if set, is applied to regenerated public images. Fix returns the IDs it changed.
+func Fix(store storage.Storage, report *Report, watermark *photo.Watermark) ([]string, error) {
+	ids := make([]string, 0, len(report.photos))
+	for id := range report.photos {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// uploads may have saved photos, or started, since Check
+	current, err := readMetadata(store)
+	if err != nil {
+		return nil, err
+	}
+	unfinished, err := jobs.NewStore(store).Unfinished()
+	if err != nil {
+		return nil, err
+	}
+
+	var fixed []string
+	adopted := make(map[string]photo.Metadata) // new entries for objects without metadata
+	trashed := make(map[string]time.Time)      // Deleted times of trashed photos
+	for _, id := range ids {
+		o := report.photos[id]
+		m, ok := report.metadata[id]
+		switch {
+		case !ok:
+			if _, ok := current[id]; ok {
+				continue
+			}
+			if _, ok := unfinished[id]; ok {
+				continue
+			}
+			m, err := adopt(store, id, o)
+			if err != nil {
+				return fixed, err
+			}
+			adopted[id] = m
+		case m.IsDeleted():
+			if !o.any() {
+				continue
+			}
+			if err := photo.MoveToTrash(store, id, m); err != nil {
+				return fixed, err
+			}
+		case !o.image && !o.original:
+			log.Print("trashing ", id, ": no image")
+			if err := photo.MoveToTrash(store, id, m); err != nil {
+				return fixed, err
+			}
+			trashed[id] = time.Now()
+		case !o.image || !o.thumbnail:
+			if !o.image {
+				if err := regenerateImage(store, id, watermark); err != nil {
+					return fixed, err
+				}
+				o.image = true
+			}
+			if !o.thumbnail {
+				if err := regenerateThumbnail(store, id, o); err != nil {
+					return fixed, err
+				}
+				o.thumbnail = true
+			}
+		default:
+			continue
+		}
+		fixed = append(fixed, id)
+	}
+
+	if len(adopted)+len(trashed) > 0 {
+		// re-read so that changes made since Check aren't lost
+		metadata, err := readMetadata(store)
+		if err != nil {
+			return fixed, err
+		}
+		for id, m := range adopted {
+			if _, ok := metadata[id]; !ok {
+				metadata[id] = m
+			}
+		}
+		for id, deleted := range trashed {
+			if m, ok := metadata[id]; ok && !m.IsDeleted() {
+				m.Deleted = deleted
+				metadata[id] = m
+			}
+		}
+		if err = store.Write(storage.BUCKET_API, storage.KEY_PHOTOS, metadata); err != nil {
+			return fixed, err
+		}
+		report.metadata = metadata
+	}
+	return fixed, nil
+}
+
+// adopt moves objects without metadata to the trash and returns a deleted metadata entry for
+// them, with what EXIF can tell about them, so that they can be restored or purged.
+func adopt(store storage.Storage, id string, o *objects) (photo.Metadata, error) {
+	log.Print("trashing ", id, ": no metadata")
+	m := photo.Metadata{
+		Filename: id,
+		Deleted:  time.Now(),
+	}
+	if o.thumbnail {
+		m.Renditions = append(m.Renditions, photo.Rendition{Name: photo.RenditionThumbnail})
+	}
+	for _, name := range o.renditions {
+		m.Renditions = append(m.Renditions, photo.Rendition{Name: name})
+	}
+	if exifData, err := readExif(store, id, o); err == nil {
+		exifData.Apply(&m)
+	}
+	return m, photo.MoveToTrash(store, id, m)
+}
+
+// regenerateImage recreates the public image from the original.
+func regenerateImage(store storage.Storage, id string, watermark *photo.Watermark) error {
+	log.Print("regenerating image for ", id)
+	r, err := store.Get(storage.BUCKET_ORIGINALS, id)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	img, err := photo.Decode(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", id, err)
+	}
+	stripped, err := photo.StripImage(watermark.Apply(img))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(stripped)
+	return store.Upload(storage.BUCKET_IMAGES, id, stripped)
+}
+
+// regenerateThumbnail recreates the thumbnail from the original, or the public image if there
+// is none.
+func regenerateThumbnail(store storage.Storage, id string, o *objects) error {
+	log.Print("regenerating thumbnail for ", id)
+	bucket := storage.BUCKET_IMAGES
+	if o.original {
+		bucket = storage.BUCKET_ORIGINALS
+	}
+	r, err := store.Get(bucket, id)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	img, err := photo.Decode(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", id, err)
+	}
+	var specs []photo.RenditionSpec
+	for _, spec := range photo.Renditions {
+		if spec.Name() == photo.RenditionThumbnail {
+			specs = append(specs, spec)
+		}
+	}
+	renditions, err := photo.RenditionsFromImage(img, specs)
+	if err != nil {
+		return err
+	}
+	defer photo.RemoveRenditions(renditions)
+	for _, rendition := range renditions {
+		bucket, key := photo.RenditionObject(id, rendition.Name)
+		if err = store.Upload(bucket, key, rendition.File); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readExif(store storage.Storage, id string, o *objects) (*photo.ExifData, error) {
+	bucket := storage.BUCKET_IMAGES
+	switch {
+	case o.original:
+		bucket = storage.BUCKET_ORIGINALS
+	case !o.image:
+		return nil, fmt.Errorf("no image")
+	}
+	r, err := store.Get(bucket, id)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return photo.GetExifData(r)
+}
+
+func readMetadata(store storage.Storage) (map[string]photo.Metadata, error) {
+	r, err := store.Get(storage.BUCKET_API, storage.KEY_PHOTOS)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	metadata := make(map[string]photo.Metadata)
+	if err = json.NewDecoder(r).Decode(&metadata); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return metadata, nil
+}
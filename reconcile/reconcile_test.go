@@ -0,0 +1,269 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/jobs"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+func newStore(t *testing.T) *storage.Local {
+	t.Helper()
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// upload stores a small JPEG at each bucket/key.
+func upload(t *testing.T, store storage.Storage, objects ...[2]string) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 200, 150))
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 150; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 100, 255})
+		}
+	}
+	name := filepath.Join(t.TempDir(), "photo.jpg")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = jpeg.Encode(f, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	for _, o := range objects {
+		if err = store.Upload(o[0], o[1], name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func exists(t *testing.T, store storage.Storage, bucket, key string) bool {
+	t.Helper()
+	ok, err := store.Exists(bucket, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok
+}
+
+func TestCheckAndFix(t *testing.T) {
+	store := newStore(t)
+	images, thumbnails, originals := storage.BUCKET_IMAGES, storage.BUCKET_THUMBNAILS, storage.BUCKET_ORIGINALS
+	upload(t, store,
+		[2]string{images, "ok"}, [2]string{thumbnails, "ok"}, [2]string{originals, "ok"},
+		[2]string{images, "nothumb"}, [2]string{originals, "nothumb"},
+		[2]string{originals, "noimage"},
+		[2]string{thumbnails, "thumbonly"},
+		[2]string{images, "orphan"},
+		[2]string{images, "deleted"},
+	)
+	metadata := map[string]photo.Metadata{
+		"ok":        {Filename: "ok"},
+		"nothumb":   {Filename: "nothumb"},
+		"noimage":   {Filename: "noimage"},
+		"thumbonly": {Filename: "thumbonly"},
+		"missing":   {Filename: "missing"},
+		"deleted":   {Filename: "deleted", Deleted: time.Now()},
+	}
+	if err := store.Write(storage.BUCKET_API, storage.KEY_PHOTOS, metadata); err != nil {
+		t.Fatal(err)
+	}
+	albums := map[string]photo.Album{
+		"al": {ID: "al", Title: "Album", Photos: []string{"ok", "missing"}},
+	}
+	if err := store.Write(storage.BUCKET_API, storage.KEY_ALBUMS, albums); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Check(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"ImagesWithoutThumbnails": {"nothumb"},
+		"ThumbnailsWithoutImages": {"thumbonly"},
+		"OriginalsWithoutImages":  {"noimage"},
+		"MetadataWithoutObjects":  {"missing", "thumbonly"},
+		"ObjectsWithoutMetadata":  {"orphan"},
+		"DeletedWithObjects":      {"deleted"},
+	}
+	got := map[string][]string{
+		"ImagesWithoutThumbnails": report.ImagesWithoutThumbnails,
+		"ThumbnailsWithoutImages": report.ThumbnailsWithoutImages,
+		"OriginalsWithoutImages":  report.OriginalsWithoutImages,
+		"MetadataWithoutObjects":  report.MetadataWithoutObjects,
+		"ObjectsWithoutMetadata":  report.ObjectsWithoutMetadata,
+		"DeletedWithObjects":      report.DeletedWithObjects,
+	}
+	for field, ids := range want {
+		if !reflect.DeepEqual(got[field], ids) {
+			t.Errorf("%s = %v, want %v", field, got[field], ids)
+		}
+	}
+	if report.OK() {
+		t.Error("OK() = true, want false")
+	}
+
+	fixed, err := Fix(store, report, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFixed := []string{"deleted", "missing", "noimage", "nothumb", "orphan", "thumbonly"}
+	if !reflect.DeepEqual(fixed, wantFixed) {
+		t.Errorf("fixed = %v, want %v", fixed, wantFixed)
+	}
+
+	report, err = Check(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("after Fix, report = %+v", report)
+	}
+	for _, o := range [][2]string{{images, "noimage"}, {thumbnails, "noimage"}, {thumbnails, "nothumb"}} {
+		if !exists(t, store, o[0], o[1]) {
+			t.Errorf("%s/%s not regenerated", o[0], o[1])
+		}
+	}
+
+	stored := report.metadata
+	for _, id := range []string{"orphan", "missing", "thumbonly", "deleted"} {
+		if !stored[id].IsDeleted() {
+			t.Errorf("%s is not deleted in photos.json", id)
+		}
+	}
+	// adopted objects can be restored like any deleted photo
+	for _, o := range photo.Objects("orphan", stored["orphan"]) {
+		if exists(t, store, o.Bucket, o.Key) {
+			t.Errorf("%s/%s not moved to the trash", o.Bucket, o.Key)
+		}
+	}
+	if err = photo.RestoreFromTrash(store, "orphan", stored["orphan"]); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(t, store, images, "orphan") {
+		t.Error("orphan not restored")
+	}
+
+	// trashed photos stay in their albums until purged
+	album := readAlbums(t, store)["al"]
+	if !reflect.DeepEqual(album.Photos, []string{"ok", "missing"}) {
+		t.Errorf("album photos = %v, want [ok missing]", album.Photos)
+	}
+}
+
+func TestFixNothing(t *testing.T) {
+	store := newStore(t)
+	report, err := Check(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Fatalf("report = %+v", report)
+	}
+	fixed, err := Fix(store, report, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixed) != 0 {
+		t.Errorf("fixed = %v", fixed)
+	}
+	if exists(t, store, storage.BUCKET_API, storage.KEY_PHOTOS) {
+		t.Error("photos.json written with nothing to fix")
+	}
+}
+
+func readAlbums(t *testing.T, store storage.Storage) map[string]photo.Album {
+	t.Helper()
+	var albums map[string]photo.Album
+	r, err := store.Get(storage.BUCKET_API, storage.KEY_ALBUMS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err = json.NewDecoder(r).Decode(&albums); err != nil {
+		t.Fatal(err)
+	}
+	return albums
+}
+
+func TestFixLeavesUploadsInProgress(t *testing.T) {
+	store := newStore(t)
+	upload(t, store, [2]string{storage.BUCKET_IMAGES, "uploading"}, [2]string{storage.BUCKET_IMAGES, "finished"})
+	job := &jobs.Job{
+		ID:     "job",
+		Status: jobs.StatusRunning,
+		Items:  []jobs.Item{{ID: "uploading", Status: jobs.StatusRunning}},
+	}
+	if err := jobs.NewStore(store).Save(job); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Check(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.InProgress, []string{"uploading"}) {
+		t.Errorf("InProgress = %v", report.InProgress)
+	}
+	if !reflect.DeepEqual(report.ObjectsWithoutMetadata, []string{"finished"}) {
+		t.Errorf("ObjectsWithoutMetadata = %v", report.ObjectsWithoutMetadata)
+	}
+
+	// a job saves its photo between Check and Fix
+	metadata := map[string]photo.Metadata{"finished": {Filename: "finished", Caption: "saved"}}
+	if err = store.Write(storage.BUCKET_API, storage.KEY_PHOTOS, metadata); err != nil {
+		t.Fatal(err)
+	}
+	fixed, err := Fix(store, report, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixed) != 0 {
+		t.Errorf("fixed = %v", fixed)
+	}
+	for _, id := range []string{"uploading", "finished"} {
+		if !exists(t, store, storage.BUCKET_IMAGES, id) {
+			t.Errorf("%s moved to the trash", id)
+		}
+	}
+}
+
+func TestFixKeepsEditsSinceCheck(t *testing.T) {
+	store := newStore(t)
+	metadata := map[string]photo.Metadata{"gone": {Filename: "gone", Caption: "before"}}
+	if err := store.Write(storage.BUCKET_API, storage.KEY_PHOTOS, metadata); err != nil {
+		t.Fatal(err)
+	}
+	report, err := Check(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata["gone"] = photo.Metadata{Filename: "gone", Caption: "edited"}
+	if err = store.Write(storage.BUCKET_API, storage.KEY_PHOTOS, metadata); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Fix(store, report, nil); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := readMetadata(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := stored["gone"]; m.Caption != "edited" || !m.IsDeleted() {
+		t.Errorf("photos.json entry = %+v, want the edit kept and deleted", m)
+	}
+}
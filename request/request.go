@@ -1,12 +1,59 @@
 package request
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+const (
+	StatusApproved = "approved"
+	StatusHeld     = "held"
+	StatusRejected = "rejected"
+)
 
 type Request struct {
+	ID      string    `json:"id,omitempty"`
 	Time    time.Time `json:"time"`
 	Session string    `json:"session"`
 	Name    string    `json:"name"`
 	Message string    `json:"message"`
 	Song    string    `json:"song"`
 	Artist  string    `json:"artist"`
+	Status  string    `json:"status,omitempty"` // empty for requests stored before moderation; treated as approved
+	Flags   []string  `json:"flags,omitempty"`  // moderation reasons
+}
+
+// Public is the anonymous view of a Request. Fields added to Request are private
+// until they are explicitly copied here in Public().
+type Public struct {
+	ID        string    `json:"id,omitempty"`
+	Time      time.Time `json:"time"`
+	Song      string    `json:"song"`
+	Artist    string    `json:"artist"`
+	FirstName string    `json:"firstName"`
+	Status    string    `json:"status"`
+}
+
+// Visible reports whether the request has passed moderation.
+func (r Request) Visible() bool {
+	return r.Status == "" || r.Status == StatusApproved
+}
+
+func (r Request) Public() Public {
+	status := r.Status
+	if status == "" {
+		status = StatusApproved
+	}
+	var firstName string
+	if fields := strings.Fields(r.Name); len(fields) > 0 {
+		firstName = fields[0]
+	}
+	return Public{
+		ID:        r.ID,
+		Time:      r.Time,
+		Song:      r.Song,
+		Artist:    r.Artist,
+		FirstName: firstName,
+		Status:    status,
+	}
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+/*
+strip
+Backfills EXIF/GPS stripping for images uploaded before originals were kept privately.
+1. Lists images in the images bucket that have no copy in the originals bucket
+2. Copies each image to the private originals bucket
+3. Merges its EXIF data into photos.json
+4. Replaces the public image with a re-encoded copy without metadata
+
+placeholders
+Backfills BlurHash and average/dominant color placeholders for photos uploaded before they were
+computed, decoding each photo without a BlurHash (or every photo with -all) from the images bucket.
+*/
+
+func runStrip(e *env, args []string) error {
+	flags := newFlagSet("strip")
+	flags.Parse(args)
+
+	keys, err := e.Storage.List(storage.BUCKET_IMAGES)
+	if err != nil {
+		return err
+	}
+	originals, err := listSet(e.Storage, storage.BUCKET_ORIGINALS)
+	if err != nil {
+		return err
+	}
+	var todo []string
+	for _, key := range keys {
+		if _, ok := originals[key]; !ok {
+			todo = append(todo, key)
+		}
+	}
+	if e.DryRun {
+		for _, key := range todo {
+			log.Print("dry run: would strip ", key)
+		}
+		return nil
+	}
+	metadata, err := getPhotoData(e.Storage)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	return e.forEach("stripping", todo, func(key string) error {
+		exifData, err := stripPhoto(e.Storage, key)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		datum := metadata[key]
+		datum.Filename = key
+		exifData.Apply(&datum)
+		metadata[key] = datum
+		// write as we go: once its original is kept, a photo isn't stripped again, so an interrupted
+		// run would lose its EXIF data
+		return e.writePhotoData(metadata)
+	})
+}
+
+// stripPhoto keeps the public image of key as its private original, replaces it with a copy
+// without metadata and returns the original's EXIF data.
+func stripPhoto(store storage.Storage, key string) (*photo.ExifData, error) {
+	r, err := store.Get(storage.BUCKET_IMAGES, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	tmp, err := os.CreateTemp("", "original.*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	tmp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = store.UploadPrivate(storage.BUCKET_ORIGINALS, key, tmp.Name()); err != nil {
+		return nil, err
+	}
+	exifData, err := photo.GetFileExifData(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	stripped, err := photo.StripMetadata(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(stripped)
+	return exifData, store.Upload(storage.BUCKET_IMAGES, key, stripped)
+}
+
+func runPlaceholders(e *env, args []string) error {
+	flags := newFlagSet("placeholders")
+	all := flags.Bool("all", false, "recompute placeholders that are already set")
+	flags.Parse(args)
+
+	metadata, err := getPhotoData(e.Storage)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, key := range sortedKeys(metadata) {
+		if metadata[key].BlurHash == "" || *all {
+			keys = append(keys, key)
+		}
+	}
+	if e.DryRun {
+		for _, key := range keys {
+			log.Print("dry run: would compute placeholders for ", key)
+		}
+		return nil
+	}
+
+	var mu sync.Mutex
+	var updated int
+	ferr := e.forEach("computing placeholders for", keys, func(key string) error {
+		r, err := e.Storage.Get(storage.BUCKET_IMAGES, key)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		img, err := photo.Decode(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		datum := metadata[key]
+		datum.SetPlaceholders(img)
+		metadata[key] = datum
+		updated++
+		return nil
+	})
+	if updated > 0 {
+		if err = e.writePhotoData(metadata); err != nil {
+			return err
+		}
+		log.Print("updated ", updated, " photos")
+	}
+	return ferr
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+/*
+exif-backfill
+1. Reads each file in the images bucket from the originals bucket, or the images bucket if not yet stripped
+2. Gets its EXIF data and perceptual hash
+3. Merges them into photos.json, reverse geocoding through the geocode cache
+   (PositionStack with POSITIONSTACK_KEY, or the bundled places dataset with -offline)
+
+With -dry-run, prints the fields that would change, without geocoding.
+*/
+
+func runExifBackfill(e *env, args []string) error {
+	flags := newFlagSet("exif-backfill")
+	offline := flags.Bool("offline", false, "reverse geocode with the bundled places dataset")
+	flags.Parse(args)
+
+	// a dry run doesn't geocode: PositionStack lookups are paid, and misses are written to the cache
+	var geocoder photo.Geocoder
+	var err error
+	if !e.DryRun {
+		if geocoder, err = e.geocoder(*offline); err != nil {
+			return err
+		}
+	}
+	metadata, err := getPhotoData(e.Storage)
+	if err != nil {
+		return err
+	}
+	keys, err := e.Storage.List(storage.BUCKET_IMAGES)
+	if err != nil {
+		return err
+	}
+	originals, err := listSet(e.Storage, storage.BUCKET_ORIGINALS)
+	if err != nil {
+		return err
+	}
+
+	var (
+		mu     sync.Mutex
+		misses int // photos with GPS coordinates that didn't geocode
+	)
+	ferr := e.forEach("reading exif data for", keys, func(key string) error {
+		bucket := storage.BUCKET_IMAGES // public images are stripped once their original is kept
+		if _, ok := originals[key]; ok {
+			bucket = storage.BUCKET_ORIGINALS
+		}
+		r, err := e.Storage.Get(bucket, key)
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		exifData, err := photo.GetExifData(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		hash, err := photo.HashReader(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		var location *photo.Location
+		if (exifData.GPSLatitude != 0 || exifData.GPSLongitude != 0) && geocoder != nil {
+			if location, err = exifData.GetLocation(e.ctx, geocoder); err != nil {
+				return err
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		before := metadata[key]
+		datum := before
+		datum.Filename = key
+		exifData.Apply(&datum)
+		datum.Hash = hash
+		if location != nil {
+			datum.Location = location
+		} else if geocoder != nil && (exifData.GPSLatitude != 0 || exifData.GPSLongitude != 0) {
+			misses++
+		}
+		metadata[key] = datum
+		if e.DryRun {
+			changes, err := audit.Diff(before, datum)
+			if err != nil {
+				return err
+			}
+			for field, change := range changes {
+				log.Printf("dry run: %s %s: %v -> %v", key, field, change.Before, change.After)
+			}
+		}
+		return nil
+	})
+	// keep what was read even if some photos failed
+	if err = e.writePhotoData(metadata); err != nil {
+		return err
+	}
+	if misses > 0 {
+		log.Print(misses, " photos with GPS coordinates have no location; geocode them with POSITIONSTACK_KEY or a fuller PLACES_FILE")
+	}
+	log.Print("got exif data")
+	return ferr
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+/*
+export
+Copies the photo library to -dir as photos.json, albums.json and originals/<id>: the original of each
+photo, or its public image for photos uploaded before originals were kept. Photos in the trash are
+not exported. Files already in -dir are kept, so an interrupted export can be rerun.
+
+import
+Uploads an export from -dir through the upload pipeline, which recreates the renditions and the
+public image, and merges its photos and albums into the library. Photos that already exist are
+skipped unless -overwrite is set.
+*/
+
+const (
+	exportOriginals = "originals"
+	exportPhotos    = "photos.json"
+	exportAlbums    = "albums.json"
+)
+
+func runExport(e *env, args []string) error {
+	flags := newFlagSet("export")
+	dir := flags.String("dir", "", "directory to export to")
+	flags.Parse(args)
+	if *dir == "" {
+		return fmt.Errorf("-dir required")
+	}
+
+	metadata, err := getPhotoData(e.Storage)
+	if err != nil {
+		return err
+	}
+	albums, err := getAlbums(e.Storage)
+	if err != nil {
+		return err
+	}
+	keys := sortedKeys(metadata)
+	if e.DryRun {
+		log.Print("dry run: would export ", len(keys), " photos and ", len(albums), " albums to ", *dir)
+		return nil
+	}
+	if err = os.MkdirAll(filepath.Join(*dir, exportOriginals), 0755); err != nil {
+		return err
+	}
+
+	ferr := e.forEach("exporting", keys, func(key string) error {
+		name := filepath.Join(*dir, exportOriginals, key)
+		if _, err := os.Stat(name); err == nil {
+			return nil
+		}
+		tmp, err := e.source(key)
+		if err != nil {
+			return err
+		}
+		if tmp == "" {
+			return fmt.Errorf("no original or image")
+		}
+		defer os.Remove(tmp)
+		return copyFile(tmp, name)
+	})
+
+	exported := make(map[string]photo.Metadata)
+	for _, key := range keys {
+		exported[key] = metadata[key]
+	}
+	if err = writeJSON(filepath.Join(*dir, exportPhotos), exported); err != nil {
+		return err
+	}
+	if err = writeJSON(filepath.Join(*dir, exportAlbums), albums); err != nil {
+		return err
+	}
+	return ferr
+}
+
+func runImport(e *env, args []string) error {
+	flags := newFlagSet("import")
+	dir := flags.String("dir", "", "directory holding an export")
+	overwrite := flags.Bool("overwrite", false, "replace photos and albums that already exist")
+	offline := flags.Bool("offline", false, "reverse geocode with the bundled places dataset")
+	flags.Parse(args)
+	if *dir == "" {
+		return fmt.Errorf("-dir required")
+	}
+
+	imported := make(map[string]photo.Metadata)
+	if err := readJSON(filepath.Join(*dir, exportPhotos), &imported); err != nil {
+		return err
+	}
+	importedAlbums := make(map[string]photo.Album)
+	if err := readJSON(filepath.Join(*dir, exportAlbums), &importedAlbums); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	metadata, err := getPhotoData(e.Storage)
+	if err != nil {
+		return err
+	}
+	var keys []string
+	for _, key := range sortedKeys(imported) {
+		if _, ok := metadata[key]; ok && !*overwrite {
+			log.Print("skipping ", key, ": already exists")
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if e.DryRun {
+		log.Print("dry run: would import ", len(keys), " photos from ", *dir)
+		return nil
+	}
+
+	geocoder, err := e.geocoder(*offline)
+	if err != nil {
+		return err
+	}
+	watermark, err := photo.WatermarkFromEnv(e.Storage)
+	if err != nil {
+		return err
+	}
+	pipeline := &photo.Pipeline{Storage: e.Storage, Geocoder: geocoder, Watermark: watermark}
+	processed := make(map[string]photo.Metadata)
+	var mu sync.Mutex
+	ferr := e.forEach("importing", keys, func(key string) error {
+		datum, err := pipeline.Process(e.ctx, key, filepath.Join(*dir, exportOriginals, key), imported[key])
+		if err != nil {
+			if _, ok := metadata[key]; !ok {
+				pipeline.Remove(key)
+			}
+			return err
+		}
+		if uploaded := imported[key].Uploaded; !uploaded.IsZero() {
+			datum.Uploaded = uploaded
+		}
+		mu.Lock()
+		processed[key] = datum
+		mu.Unlock()
+		return nil
+	})
+
+	if len(processed) > 0 {
+		if err = e.mergePhotos(metadata, processed); err != nil {
+			return err
+		}
+	}
+	if err = e.mergeAlbums(metadata, importedAlbums, *overwrite); err != nil {
+		return err
+	}
+	return ferr
+}
+
+// mergePhotos writes processed into photos.json, which holds metadata, and audits the upload.
+func (e *env) mergePhotos(metadata, processed map[string]photo.Metadata) error {
+	targets := make([]string, 0, len(processed))
+	before := make(map[string]interface{})
+	after := make(map[string]interface{})
+	for key, datum := range processed {
+		if existing, ok := metadata[key]; ok {
+			before[key] = existing
+		}
+		after[key] = datum
+		targets = append(targets, key)
+	}
+	photo.UpdateMetadata(processed, metadata)
+	if err := e.writePhotoData(metadata); err != nil {
+		return err
+	}
+	return e.audit(audit.ActionPhotosUpload, targets, before, after)
+}
+
+// mergeAlbums adds imported albums that are valid against metadata to albums.json.
+func (e *env) mergeAlbums(metadata map[string]photo.Metadata, imported map[string]photo.Album, overwrite bool) error {
+	albums, err := getAlbums(e.Storage)
+	if err != nil {
+		return err
+	}
+	var targets []string
+	before := make(map[string]interface{})
+	after := make(map[string]interface{})
+	for id, album := range imported {
+		existing, ok := albums[id]
+		if ok && !overwrite {
+			continue
+		}
+		if err := album.Validate(metadata); err != nil {
+			log.Print("skipping album ", id, ": ", err)
+			continue
+		}
+		if ok {
+			before[id] = existing
+		}
+		albums[id] = album
+		after[id] = album
+		targets = append(targets, id)
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	if err = e.Storage.Write(storage.BUCKET_API, storage.KEY_ALBUMS, albums); err != nil {
+		return err
+	}
+	log.Print("imported ", len(targets), " albums")
+	return e.audit(audit.ActionAlbumCreate, targets, before, after)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// write beside dst and rename so an interrupted copy isn't mistaken for a finished one
+	out, err := os.CreateTemp(filepath.Dir(dst), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
+
+func writeJSON(name string, v interface{}) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readJSON(name string, v interface{}) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(f).Decode(v)
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+	"github.com/stinkyfingers/chadedwardsapi/uid"
+)
+
+/*
+import-dir
+Uploads every image under -dir through the upload pipeline, e.g. the photos from a festival.
+1. Walks -dir for JPEG, PNG, GIF and WebP files, skipping files recorded in -dir/.import-progress.json
+2. Skips images already in the library (the same file, or a perceptual hash within -threshold of a
+   stored photo) and files whose photo is in the trash
+3. Extracts EXIF data, creates renditions and uploads each image, with -tags and -category applied
+4. Merges metadata into photos.json every few photos, recording progress so an interrupted import can be
+   rerun, and adds the photos to -album, an album id or title, which is created if it doesn't exist
+*/
+
+const (
+	progressFile       = ".import-progress.json"
+	checkpointInterval = 20 // photos between writes of photos.json and the progress file
+)
+
+// importer holds the state of an import-dir run shared by its workers.
+type importer struct {
+	*env
+	dir       string
+	pipeline  *photo.Pipeline
+	base      photo.Metadata
+	threshold int
+
+	mu       sync.Mutex
+	metadata map[string]photo.Metadata
+	progress map[string]string         // file relative to dir: photo id, or the id of the photo it duplicates
+	claimed  map[string]photo.Metadata // hashes of photos being imported, to catch duplicates within dir
+	pending  map[string]photo.Metadata // imported since the last checkpoint
+	imported []string
+}
+
+func runImportDir(e *env, args []string) error {
+	flags := newFlagSet("import-dir")
+	dir := flags.String("dir", "", "directory of images to import")
+	tags := flags.String("tags", "", "comma separated tags for every photo")
+	category := flags.String("category", "", "category for every photo")
+	albumName := flags.String("album", "", "id or title of the album to add the photos to")
+	threshold := flags.Int("threshold", 0, "perceptual hash distance at which an image counts as already imported")
+	offline := flags.Bool("offline", false, "reverse geocode with the bundled places dataset")
+	flags.Parse(args)
+	if *dir == "" {
+		return fmt.Errorf("-dir required")
+	}
+
+	base := photo.Metadata{Category: *category}
+	for _, tag := range strings.Split(*tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			base.Tags = append(base.Tags, tag)
+		}
+	}
+	if err := base.Validate(); err != nil {
+		return err
+	}
+	im := &importer{
+		env:       e,
+		dir:       *dir,
+		base:      base,
+		threshold: *threshold,
+		progress:  make(map[string]string),
+		claimed:   make(map[string]photo.Metadata),
+		pending:   make(map[string]photo.Metadata),
+	}
+	if err := readJSON(filepath.Join(*dir, progressFile), &im.progress); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	files, err := im.files()
+	if err != nil {
+		return err
+	}
+	if e.DryRun {
+		log.Print("dry run: would import ", len(files), " files from ", *dir)
+		return nil
+	}
+	if im.metadata, err = getPhotoData(e.Storage); err != nil {
+		return err
+	}
+	geocoder, err := e.geocoder(*offline)
+	if err != nil {
+		return err
+	}
+	watermark, err := photo.WatermarkFromEnv(e.Storage)
+	if err != nil {
+		return err
+	}
+	im.pipeline = &photo.Pipeline{Storage: e.Storage, Geocoder: geocoder, Watermark: watermark}
+
+	ferr := e.forEach("importing", files, im.importFile)
+	if err = im.checkpoint(); err != nil {
+		return err
+	}
+	if *albumName != "" {
+		if err = im.addToAlbum(*albumName); err != nil {
+			return err
+		}
+	}
+	log.Print("imported ", len(im.imported), " photos")
+	return ferr
+}
+
+// files returns the images under dir that aren't recorded in the progress file, relative to dir.
+func (im *importer) files() ([]string, error) {
+	var files []string
+	err := filepath.Walk(im.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") && path != im.dir {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(im.dir, path)
+		if err != nil {
+			return err
+		}
+		if _, ok := im.progress[rel]; ok {
+			return nil
+		}
+		if _, err = photo.DetectFormat(path); err == photo.ErrUnsupportedFormat {
+			log.Print("skipping ", rel, ": ", err)
+			return nil
+		}
+		files = append(files, rel)
+		return err
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// importFile uploads the image at rel unless it's already in the library.
+func (im *importer) importFile(rel string) error {
+	name := filepath.Join(im.dir, rel)
+	id, err := fileID(name)
+	if err != nil {
+		return err
+	}
+	hash, err := fileHash(name)
+	if err != nil {
+		return err
+	}
+	if existing, trashed := im.claim(id, hash); existing != "" {
+		if trashed {
+			log.Print("skipping ", rel, ": in the trash as ", existing, ", restore it instead")
+		} else {
+			log.Print("skipping ", rel, ": already imported as ", existing)
+		}
+		return im.record(rel, existing, nil)
+	}
+
+	metadata := im.base
+	metadata.Tags = append([]string(nil), im.base.Tags...)
+	metadata, err = im.pipeline.Process(im.ctx, id, name, metadata)
+	if err != nil {
+		im.pipeline.Remove(id)
+		im.unclaim(id)
+		return err
+	}
+	return im.record(rel, id, &metadata)
+}
+
+// claim returns the id of a stored or claimed photo that id or hash duplicates, and whether it is
+// in the trash, or, if there is none, claims hash for id. A trashed photo with the same id is never
+// replaced: its trashed objects would be overwritten and then never purged.
+func (im *importer) claim(id, hash string) (string, bool) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	for _, metadata := range []map[string]photo.Metadata{im.metadata, im.claimed} {
+		if m, ok := metadata[id]; ok {
+			return id, m.IsDeleted()
+		}
+		if duplicates := photo.Duplicates(metadata, id, hash, im.threshold); len(duplicates) > 0 {
+			return duplicates[0], false
+		}
+	}
+	im.claimed[id] = photo.Metadata{Hash: hash}
+	return "", false
+}
+
+func (im *importer) unclaim(id string) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	delete(im.claimed, id)
+}
+
+// record notes that rel was imported as id, or skipped as a duplicate of id if metadata is nil,
+// and checkpoints every checkpointInterval photos.
+func (im *importer) record(rel, id string, metadata *photo.Metadata) error {
+	im.mu.Lock()
+	im.progress[rel] = id
+	if metadata != nil {
+		im.pending[id] = *metadata
+	}
+	checkpoint := len(im.pending) >= checkpointInterval
+	im.mu.Unlock()
+	if checkpoint {
+		return im.checkpoint()
+	}
+	return nil
+}
+
+// checkpoint merges the photos imported since the last checkpoint into photos.json, audits them and
+// writes the progress file. The progress file is written last so that a file is only skipped on a
+// rerun once its metadata is stored.
+func (im *importer) checkpoint() error {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if len(im.pending) > 0 {
+		targets := make([]string, 0, len(im.pending))
+		after := make(map[string]interface{})
+		for id, datum := range im.pending {
+			after[id] = datum
+			targets = append(targets, id)
+			im.imported = append(im.imported, id)
+		}
+		// re-read so that changes made by the server during the import aren't lost
+		metadata, err := getPhotoData(im.Storage)
+		if err != nil {
+			return err
+		}
+		photo.UpdateMetadata(im.pending, metadata)
+		if err = im.writePhotoData(metadata); err != nil {
+			return err
+		}
+		im.metadata = metadata
+		if err := im.audit(audit.ActionPhotosUpload, targets, nil, after); err != nil {
+			return err
+		}
+		im.pending = make(map[string]photo.Metadata)
+	}
+	return writeJSON(filepath.Join(im.dir, progressFile), im.progress)
+}
+
+// addToAlbum adds the photos in dir to the album with id or title name, creating it if needed.
+func (im *importer) addToAlbum(name string) error {
+	albums, err := getAlbums(im.Storage)
+	if err != nil {
+		return err
+	}
+	album, ok := albums[name]
+	if !ok {
+		for _, a := range albums {
+			if a.Title == name {
+				album, ok = a, true
+				break
+			}
+		}
+	}
+	var before interface{}
+	action := audit.ActionAlbumCreate
+	if ok {
+		before = album
+		action = audit.ActionAlbumUpdate
+	} else {
+		album = photo.Album{Title: name, Date: time.Now()}
+		if album.ID, err = uid.New(); err != nil {
+			return err
+		}
+	}
+	// every photo in dir, including those imported by earlier runs or already in the library,
+	// in the order they were taken
+	var ids []string
+	seen := make(map[string]struct{})
+	for _, id := range im.progress {
+		if _, ok := seen[id]; ok || album.Contains(id) {
+			continue
+		}
+		if m, ok := im.metadata[id]; ok && !m.IsDeleted() {
+			ids = append(ids, id)
+			seen[id] = struct{}{}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return im.metadata[ids[i]].DateTimeOriginal.Before(im.metadata[ids[j]].DateTimeOriginal)
+	})
+	if len(ids) == 0 {
+		return nil
+	}
+	album.Photos = append(album.Photos, ids...)
+	if err = album.Validate(im.metadata); err != nil {
+		return err
+	}
+	albums[album.ID] = album
+	if err = im.Storage.Write(storage.BUCKET_API, storage.KEY_ALBUMS, albums); err != nil {
+		return err
+	}
+	log.Print("added ", len(ids), " photos to album ", album.ID)
+	return im.audit(action, []string{album.ID}, map[string]interface{}{album.ID: before}, map[string]interface{}{album.ID: album})
+}
+
+// fileID derives the photo id from the file content, so that rerunning an interrupted import
+// overwrites the objects it already uploaded instead of orphaning them.
+func fileID(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// fileHash returns the perceptual hash of the image at name.
+func fileHash(name string) (string, error) {
+	jpg, _, err := photo.Normalize(name)
+	if err != nil {
+		return "", err
+	}
+	if jpg != name {
+		defer os.Remove(jpg)
+	}
+	img, err := photo.Open(jpg)
+	if err != nil {
+		return "", err
+	}
+	return photo.Hash(img), nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+/*
+Administers the photo buckets and chadedwardsapi/photos.json.
+
+	admin [-profile jds] [-storage s3|local] [-root dir] [-concurrency n] [-dry-run] <command> [flags]
+
+Commands:
+	exif-backfill  merges EXIF data, locations and hashes from the originals into photos.json
+	thumbnails     creates missing thumbnails from the originals
+	renditions     creates missing renditions from the originals
+	reconcile      checks the buckets against photos.json and, with -fix, repairs them
+	export         copies photos.json, albums.json and the originals to a directory
+	import         uploads an export through the upload pipeline
+	import-dir     uploads a directory of images through the upload pipeline
+	strip          keeps originals of images uploaded before they were kept, and strips their metadata
+	placeholders   computes missing BlurHash and color placeholders
+	purge          permanently removes photos that have been in the trash longer than the retention period
+
+Run "admin <command> -h" for the flags of each command. With -storage local, buckets are
+directories under -root, which is useful for trying a command against a copy of the data.
+*/
+
+const actor = "scripts/admin"
+
+type command struct {
+	name  string
+	usage string
+	run   func(env *env, args []string) error
+}
+
+var commands = []command{
+	{"exif-backfill", "merge EXIF data, locations and hashes from the originals into photos.json", runExifBackfill},
+	{"thumbnails", "create missing thumbnails from the originals", runThumbnails},
+	{"renditions", "create missing renditions from the originals", runRenditions},
+	{"reconcile", "check the buckets against photos.json", runReconcile},
+	{"export", "copy photos.json, albums.json and the originals to a directory", runExport},
+	{"import", "upload an export through the upload pipeline", runImport},
+	{"import-dir", "upload a directory of images through the upload pipeline", runImportDir},
+	{"strip", "keep originals of images uploaded before they were kept, and strip their metadata", runStrip},
+	{"placeholders", "compute missing BlurHash and color placeholders", runPlaceholders},
+	{"purge", "permanently remove photos that have been in the trash past the retention period", runPurge},
+}
+
+// env holds the flags shared by every command.
+type env struct {
+	ctx         context.Context
+	Storage     storage.Storage
+	Concurrency int
+	DryRun      bool
+}
+
+func main() {
+	profile := flag.String("profile", "jds", "AWS profile for s3 storage")
+	backend := flag.String("storage", "s3", "storage backend: s3 or local")
+	root := flag.String("root", "data", "directory holding the buckets for local storage")
+	concurrency := flag.Int("concurrency", 4, "photos processed at once")
+	dryRun := flag.Bool("dry-run", false, "report what would change without changing it")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == flag.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintln(os.Stderr, "unknown command:", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+
+	var store storage.Storage
+	var err error
+	switch *backend {
+	case "s3":
+		store, err = storage.NewS3(*profile)
+	case "local":
+		store, err = storage.NewLocal(*root)
+	default:
+		err = fmt.Errorf("unknown storage backend: %s", *backend)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	e := &env{
+		ctx:         context.Background(),
+		Storage:     store,
+		Concurrency: *concurrency,
+		DryRun:      *dryRun,
+	}
+	if err = cmd.run(e, flag.Args()[1:]); err != nil {
+		log.Fatal(cmd.name, ": ", err)
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "usage: admin [flags] <command> [command flags]")
+	fmt.Fprintln(out, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-14s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(out, "\nflags:")
+	flag.PrintDefaults()
+}
+
+// newFlagSet returns the flag set for a command, printing usage on error.
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("admin "+name, flag.ExitOnError)
+}
+
+// forEach calls fn for each key from Concurrency goroutines, logging progress as it goes. A failed
+// key is logged and doesn't stop the others; forEach returns an error if any failed.
+func (e *env) forEach(verb string, keys []string, fn func(key string) error) error {
+	total := len(keys)
+	var done, failed int64
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < e.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range ch {
+				err := fn(key)
+				n := atomic.AddInt64(&done, 1)
+				if err != nil {
+					atomic.AddInt64(&failed, 1)
+					log.Printf("[%d/%d] error %s %s: %v", n, total, verb, key, err)
+					continue
+				}
+				log.Printf("[%d/%d] %s %s", n, total, verb, key)
+			}
+		}()
+	}
+	for _, key := range keys {
+		ch <- key
+	}
+	close(ch)
+	wg.Wait()
+	if failed > 0 {
+		return fmt.Errorf("%d of %d failed", failed, total)
+	}
+	return nil
+}
+
+// writePhotoData writes photos.json unless this is a dry run.
+func (e *env) writePhotoData(metadata map[string]photo.Metadata) error {
+	if e.DryRun {
+		log.Print("dry run: not writing ", storage.KEY_PHOTOS)
+		return nil
+	}
+	return e.Storage.Write(storage.BUCKET_API, storage.KEY_PHOTOS, metadata)
+}
+
+// audit records an entry by this command, see audit.NewEntry.
+func (e *env) audit(action string, targets []string, before, after map[string]interface{}) error {
+	entry, err := audit.NewEntry(actor, action, targets, before, after)
+	if err != nil {
+		return err
+	}
+	return audit.NewLog(e.Storage).Append(entry)
+}
+
+// geocoder returns a PositionStack geocoder behind the geocode cache when POSITIONSTACK_KEY is set
+// and offline is false, otherwise the offline geocoder. Offline results aren't cached, so its misses
+// don't hide PositionStack results later.
+func (e *env) geocoder(offline bool) (photo.Geocoder, error) {
+	if key := os.Getenv("POSITIONSTACK_KEY"); key != "" && !offline {
+		return photo.NewCachedGeocoder(photo.NewPositionStack(key), photo.NewStorageCache(e.Storage)), nil
+	}
+	return photo.NewOffline()
+}
+
+// download copies an object to a temp file, which the caller must remove. It returns "" if the
+// object doesn't exist.
+func (e *env) download(bucket, key string) (string, error) {
+	r, err := e.Storage.Get(bucket, key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	tmp, err := os.CreateTemp("", "admin.*")
+	if err != nil {
+		return "", err
+	}
+	n, err := io.Copy(tmp, r)
+	tmp.Close()
+	if err != nil || n == 0 {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// source downloads the original of photo id or, for photos uploaded before originals were kept,
+// the public image. It returns "" if neither exists.
+func (e *env) source(id string) (string, error) {
+	name, err := e.download(storage.BUCKET_ORIGINALS, id)
+	if name != "" || err != nil {
+		return name, err
+	}
+	return e.download(storage.BUCKET_IMAGES, id)
+}
+
+func getPhotoData(store storage.Storage) (map[string]photo.Metadata, error) {
+	data := make(map[string]photo.Metadata)
+	r, err := store.Get(storage.BUCKET_API, storage.KEY_PHOTOS)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	err = json.NewDecoder(r).Decode(&data)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+func getAlbums(store storage.Storage) (map[string]photo.Album, error) {
+	albums := make(map[string]photo.Album)
+	r, err := store.Get(storage.BUCKET_API, storage.KEY_ALBUMS)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	err = json.NewDecoder(r).Decode(&albums)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return albums, nil
+}
+
+func listSet(store storage.Storage, bucket string) (map[string]struct{}, error) {
+	keys, err := store.List(bucket)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]struct{})
+	for _, key := range keys {
+		m[key] = struct{}{}
+	}
+	return m, nil
+}
+
+// sortedKeys returns the keys of the photos that aren't in the trash, in order.
+func sortedKeys(metadata map[string]photo.Metadata) []string {
+	keys := make([]string, 0, len(metadata))
+	for key, datum := range metadata {
+		if !datum.IsDeleted() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+/*
+purge
+Permanently removes photos that have been in the trash longer than -retention.
+1. Reads metadata from photos.json
+2. Deletes the trashed objects of each photo deleted before now - retention
+3. Removes their metadata, writes it back to photos.json and records an audit entry
+4. Removes them from albums, which keep deleted photos until they are purged
+*/
+
+func runPurge(e *env, args []string) error {
+	flags := newFlagSet("purge")
+	retention := flags.Duration("retention", photo.DefaultRetention, "how long deleted photos are kept")
+	flags.Parse(args)
+
+	metadata, err := getPhotoData(e.Storage)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-*retention)
+	var keys []string
+	for key, datum := range metadata {
+		if datum.IsDeleted() && !datum.Deleted.After(cutoff) {
+			keys = append(keys, key)
+		}
+	}
+	if e.DryRun {
+		for _, key := range keys {
+			log.Print("dry run: would purge ", key, ", deleted ", metadata[key].Deleted.Format(time.RFC3339))
+		}
+		return nil
+	}
+
+	purged := make(map[string]photo.Metadata)
+	var mu sync.Mutex
+	ferr := e.forEach("purging", keys, func(key string) error {
+		if err := photo.PurgeTrash(e.Storage, key, metadata[key]); err != nil {
+			return err
+		}
+		mu.Lock()
+		purged[key] = metadata[key]
+		mu.Unlock()
+		return nil
+	})
+	if len(purged) == 0 {
+		return ferr
+	}
+
+	// re-read so that changes made while purging aren't lost
+	if metadata, err = getPhotoData(e.Storage); err != nil {
+		return err
+	}
+	targets := make([]string, 0, len(purged))
+	before := make(map[string]interface{})
+	for key, datum := range purged {
+		// a photo restored, or deleted again, while purging keeps its metadata
+		if current, ok := metadata[key]; !ok || !current.Deleted.Equal(datum.Deleted) {
+			log.Print("keeping metadata of ", key, ": restored or deleted again while purging, its trashed objects are gone")
+			delete(purged, key)
+			continue
+		}
+		delete(metadata, key)
+		targets = append(targets, key)
+		before[key] = datum
+	}
+	if len(targets) == 0 {
+		return ferr
+	}
+	if err = e.writePhotoData(metadata); err != nil {
+		return err
+	}
+	if err = e.audit(audit.ActionPhotoPurge, targets, before, nil); err != nil {
+		return err
+	}
+	if err = removeFromAlbums(e.Storage, purged); err != nil {
+		return err
+	}
+	log.Print("purged ", len(purged), " photos")
+	return ferr
+}
+
+// removeFromAlbums drops purged photos from every album that contains them.
+func removeFromAlbums(store storage.Storage, purged map[string]photo.Metadata) error {
+	albums, err := getAlbums(store)
+	if err != nil {
+		return err
+	}
+	var changed bool
+	for id, album := range albums {
+		for key := range purged {
+			if album.Remove(key) {
+				albums[id] = album
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return store.Write(storage.BUCKET_API, storage.KEY_ALBUMS, albums)
+}
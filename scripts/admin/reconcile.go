@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/reconcile"
+)
+
+/*
+reconcile
+1. Lists every bucket and reads metadata from photos.json
+2. Prints images without thumbnails, thumbnails without images, originals without images, metadata
+   without objects, objects without metadata and deleted photos whose objects are not in the trash
+3. With -fix, regenerates missing images and thumbnails from the originals, and moves anything that
+   can't be regenerated to the trash, to be restored or purged like a deleted photo
+
+Objects of unfinished upload jobs have no metadata yet; they're listed as in progress and left alone.
+*/
+
+func runReconcile(e *env, args []string) error {
+	flags := newFlagSet("reconcile")
+	fix := flags.Bool("fix", false, "repair the problems found")
+	flags.Parse(args)
+
+	report, err := reconcile.Check(e.Storage)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(report); err != nil {
+		return err
+	}
+	if report.OK() || !*fix {
+		return nil
+	}
+	if e.DryRun {
+		log.Print("dry run: not fixing")
+		return nil
+	}
+
+	watermark, err := photo.WatermarkFromEnv(e.Storage)
+	if err != nil {
+		return err
+	}
+	fixed, err := reconcile.Fix(e.Storage, report, watermark)
+	log.Print("fixed ", len(fixed), " photos")
+	return err
+}
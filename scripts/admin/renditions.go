@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+/*
+thumbnails
+Creates a thumbnail for each photo in photos.json without one in the thumbnails bucket (or every
+photo with -all), from its original, or its public image for photos uploaded before originals were kept.
+
+renditions
+Creates the renditions missing from each photo's metadata (or every rendition with -all) from its
+original, watermarked as configured by WATERMARK_LOGO or WATERMARK_TEXT, and records them in photos.json.
+*/
+
+func runThumbnails(e *env, args []string) error {
+	flags := newFlagSet("thumbnails")
+	all := flags.Bool("all", false, "recreate thumbnails that already exist")
+	flags.Parse(args)
+
+	thumbnails, err := listSet(e.Storage, storage.BUCKET_THUMBNAILS)
+	if err != nil {
+		return err
+	}
+	var specs []photo.RenditionSpec
+	for _, spec := range photo.Renditions {
+		if spec.Name() == photo.RenditionThumbnail {
+			specs = append(specs, spec)
+		}
+	}
+	return e.regenerate(nil, func(key string, datum photo.Metadata) []photo.RenditionSpec {
+		if _, ok := thumbnails[key]; ok && !*all {
+			return nil
+		}
+		return specs
+	})
+}
+
+func runRenditions(e *env, args []string) error {
+	flags := newFlagSet("renditions")
+	all := flags.Bool("all", false, "recreate renditions that already exist")
+	flags.Parse(args)
+
+	watermark, err := photo.WatermarkFromEnv(e.Storage)
+	if err != nil {
+		return err
+	}
+	return e.regenerate(watermark, func(key string, datum photo.Metadata) []photo.RenditionSpec {
+		if *all {
+			return photo.Renditions
+		}
+		existing := make(map[string]struct{})
+		for _, rendition := range datum.Renditions {
+			existing[rendition.Name] = struct{}{}
+		}
+		var missing []photo.RenditionSpec
+		for _, spec := range photo.Renditions {
+			if _, ok := existing[spec.Name()]; !ok {
+				missing = append(missing, spec)
+			}
+		}
+		return missing
+	})
+}
+
+// regenerate creates the renditions returned by specs for each photo in photos.json and records them
+// in its metadata.
+func (e *env) regenerate(watermark *photo.Watermark, specs func(key string, datum photo.Metadata) []photo.RenditionSpec) error {
+	metadata, err := getPhotoData(e.Storage)
+	if err != nil {
+		return err
+	}
+	todo := make(map[string][]photo.RenditionSpec)
+	var keys []string
+	for _, key := range sortedKeys(metadata) {
+		if s := specs(key, metadata[key]); len(s) > 0 {
+			todo[key] = s
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		log.Print("nothing to do")
+		return nil
+	}
+	if e.DryRun {
+		for _, key := range keys {
+			log.Print("dry run: would create ", renditionNames(todo[key]), " for ", key)
+		}
+		return nil
+	}
+
+	pipeline := &photo.Pipeline{Storage: e.Storage, Watermark: watermark}
+	var mu sync.Mutex
+	ferr := e.forEach("creating renditions for", keys, func(key string) error {
+		name, err := e.source(key)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return fmt.Errorf("no original or image")
+		}
+		defer os.Remove(name)
+		img, err := photo.Open(name)
+		if err != nil {
+			return err
+		}
+		renditions, err := pipeline.UploadRenditions(key, img, todo[key])
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		datum := metadata[key]
+		datum.Renditions = mergeRenditions(datum.Renditions, renditions)
+		metadata[key] = datum
+		return nil
+	})
+	if err = e.writePhotoData(metadata); err != nil {
+		return err
+	}
+	return ferr
+}
+
+// mergeRenditions replaces renditions in existing with updated ones of the same name, in the order
+// of photo.Renditions.
+func mergeRenditions(existing, updated []photo.Rendition) []photo.Rendition {
+	byName := make(map[string]photo.Rendition)
+	for _, rendition := range existing {
+		byName[rendition.Name] = rendition
+	}
+	for _, rendition := range updated {
+		byName[rendition.Name] = rendition
+	}
+	merged := make([]photo.Rendition, 0, len(byName))
+	for _, spec := range photo.Renditions {
+		if rendition, ok := byName[spec.Name()]; ok {
+			merged = append(merged, rendition)
+		}
+	}
+	return merged
+}
+
+func renditionNames(specs []photo.RenditionSpec) []string {
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.Name()
+	}
+	return names
+}
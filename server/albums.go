@@ -0,0 +1,229 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+	"github.com/stinkyfingers/chadedwardsapi/uid"
+)
+
+type AlbumPhotos struct {
+	photo.Album
+	Items []PhotoDatum `json:"items"`
+}
+
+func (s *Server) readAlbums() (map[string]photo.Album, error) {
+	r, err := s.Storage.Get(storage.BUCKET_API, storage.KEY_ALBUMS)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	albums := make(map[string]photo.Album)
+	if err = json.NewDecoder(r).Decode(&albums); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return albums, nil
+}
+
+// sortedAlbums returns albums newest first, then by title.
+func sortedAlbums(albums map[string]photo.Album, publicOnly bool) []photo.Album {
+	list := []photo.Album{}
+	for _, album := range albums {
+		if publicOnly && album.Visibility != photo.VisibilityPublic {
+			continue
+		}
+		list = append(list, album)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Date.Equal(list[j].Date) {
+			return list[i].Date.After(list[j].Date)
+		}
+		return list[i].Title < list[j].Title
+	})
+	return list
+}
+
+// HandleListAlbums returns public albums.
+func (s *Server) HandleListAlbums(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	albums, err := s.readAlbums()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(sortedAlbums(albums, true))
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleListAdminAlbums returns all albums, including private ones.
+func (s *Server) HandleListAdminAlbums(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	albums, err := s.readAlbums()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(sortedAlbums(albums, false))
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleGetAlbum returns a public album with its photos in album order.
+func (s *Server) HandleGetAlbum(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		httpError(w, "missing id", http.StatusBadRequest)
+		return
+	}
+	albums, err := s.readAlbums()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	album, ok := albums[id]
+	if !ok || album.Visibility != photo.VisibilityPublic {
+		httpError(w, "album not found", http.StatusNotFound)
+		return
+	}
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := AlbumPhotos{
+		Album: album,
+		Items: []PhotoDatum{},
+	}
+	for _, name := range album.Photos {
+		if data, ok := metadata[name]; ok && !data.IsDeleted() {
+			resp.Items = append(resp.Items, newPhotoDatum(data))
+		}
+	}
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Server) HandleCreateAlbum(w http.ResponseWriter, r *http.Request) {
+	s.saveAlbum(w, r, true)
+}
+
+// HandleUpdateAlbum replaces an existing album.
+func (s *Server) HandleUpdateAlbum(w http.ResponseWriter, r *http.Request) {
+	s.saveAlbum(w, r, false)
+}
+
+func (s *Server) saveAlbum(w http.ResponseWriter, r *http.Request, create bool) {
+	if r.Method != "POST" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	var album photo.Album
+	if err := json.NewDecoder(r.Body).Decode(&album); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	albums, err := s.readAlbums()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	action := audit.ActionAlbumUpdate
+	if create {
+		action = audit.ActionAlbumCreate
+		if album.ID, err = uid.New(); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	before, ok := albums[album.ID]
+	if !create && !ok {
+		httpError(w, "album not found", http.StatusNotFound)
+		return
+	}
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err = album.Validate(metadata); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	albums[album.ID] = album
+	if err = s.Storage.Write(storage.BUCKET_API, storage.KEY_ALBUMS, albums); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	beforeMap := map[string]interface{}{}
+	if ok {
+		beforeMap[album.ID] = before
+	}
+	s.audit(r, action, []string{album.ID}, beforeMap, map[string]interface{}{album.ID: album})
+
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(album)
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Server) HandleDeleteAlbum(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		httpError(w, "missing id", http.StatusBadRequest)
+		return
+	}
+	albums, err := s.readAlbums()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	album, ok := albums[id]
+	if !ok {
+		httpError(w, "album not found", http.StatusNotFound)
+		return
+	}
+	delete(albums, id)
+	if err = s.Storage.Write(storage.BUCKET_API, storage.KEY_ALBUMS, albums); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, audit.ActionAlbumDelete, []string{id}, map[string]interface{}{id: album}, nil)
+	httpSuccess(w)
+}
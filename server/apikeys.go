@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/auth"
+)
+
+type APIKeyRequest struct {
+	Name    string    `json:"name"`
+	Scopes  []string  `json:"scopes"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+type APIKeyResponse struct {
+	Key    string       `json:"key"` // plaintext token, only returned once
+	APIKey *auth.APIKey `json:"apiKey"`
+}
+
+func (s *Server) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	keys, err := s.APIKeys.List()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(keys)
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Server) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	var req APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		httpError(w, "name required", http.StatusBadRequest)
+		return
+	}
+	if !req.Expires.IsZero() && req.Expires.Before(time.Now()) {
+		httpError(w, "expiration must be in the future", http.StatusBadRequest)
+		return
+	}
+	identity := auth.IdentityFromContext(r.Context())
+	key, apiKey, err := s.APIKeys.Create(req.Name, req.Scopes, req.Expires, identity.Actor())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidScope) {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, audit.ActionAPIKeyCreate, []string{apiKey.ID}, nil, map[string]interface{}{apiKey.ID: apiKey})
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(APIKeyResponse{
+		Key:    key,
+		APIKey: apiKey,
+	})
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Server) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		httpError(w, "missing id", http.StatusBadRequest)
+		return
+	}
+	if err := s.APIKeys.Revoke(id); err != nil {
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			httpError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, audit.ActionAPIKeyRevoke, []string{id}, nil, nil)
+	httpSuccess(w)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/auth"
+)
+
+const auditDefaultRange = time.Hour * 24 * 30
+
+// audit records a mutating call. before and after are keyed by target; either may be nil.
+// Failures are logged rather than returned since the mutation has already happened.
+func (s *Server) audit(r *http.Request, action string, targets []string, before, after map[string]interface{}) {
+	s.auditAs(actor(r), clientIP(r), action, targets, before, after)
+}
+
+// auditAs records a mutation made outside of a request, e.g. by a job on behalf of actor.
+func (s *Server) auditAs(actor, ip, action string, targets []string, before, after map[string]interface{}) {
+	entry, err := audit.NewEntry(actor, action, targets, before, after)
+	if err != nil {
+		log.Print("error diffing audit entry: ", err)
+	}
+	entry.IP = ip
+	if err = s.Audit.Append(entry); err != nil {
+		log.Print("error writing audit entry: ", err)
+	}
+}
+
+func actor(r *http.Request) string {
+	if identity := auth.IdentityFromContext(r.Context()); identity != nil {
+		return identity.Actor()
+	}
+	return ""
+}
+
+// clientIP returns the address of the peer that connected to us. Under Lambda there is no connection, so
+// it's the last X-Forwarded-For entry, which the load balancer appends; earlier entries are client-supplied.
+func clientIP(r *http.Request) string {
+	if r.RemoteAddr != "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+	forwarded := r.Header.Get("X-Forwarded-For")
+	if i := strings.LastIndex(forwarded, ","); i >= 0 {
+		forwarded = forwarded[i+1:]
+	}
+	return strings.TrimSpace(forwarded)
+}
+
+// HandleListAudit returns audit entries filtered by actor, action and from/to (RFC3339), defaulting to the last 30 days.
+// Ranges longer than audit.MaxQueryRange are rejected.
+func (s *Server) HandleListAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		From:   time.Now().Add(-auditDefaultRange),
+		To:     time.Now(),
+	}
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			httpError(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			httpError(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	entries, err := s.Audit.Query(filter)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(entries)
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
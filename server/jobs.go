@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/jobs"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+	"github.com/stinkyfingers/chadedwardsapi/uid"
+)
+
+// enqueueJob saves a job for items, hands it to the queue and responds with the queued job.
+// Near-duplicate photos are rejected if the request has ?duplicates=reject.
+func (s *Server) enqueueJob(w http.ResponseWriter, r *http.Request, items []jobs.Item) {
+	id, err := uid.New()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range items {
+		items[i].Status = jobs.StatusQueued
+	}
+	job := &jobs.Job{
+		ID:      id,
+		Created: time.Now(),
+		Status:  jobs.StatusQueued,
+		Actor:   actor(r),
+		IP:      clientIP(r),
+		Items:   items,
+
+		RejectDuplicates: r.URL.Query().Get("duplicates") == "reject",
+	}
+	if err = s.Jobs.Save(job); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err = s.Queue.Enqueue(job.ID); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(job); err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// RunJob processes every unfinished item of a job, up to s.Concurrency at a time, recording
+// per-item status as it goes so that the job can be polled, and re-run if the worker dies part way
+// through. A failed item doesn't affect the others.
+func (s *Server) RunJob(ctx context.Context, id string) error {
+	job, err := s.Jobs.Get(id)
+	if err != nil {
+		return err
+	}
+	existing, err := s.readPhotoMetadata()
+	if err != nil {
+		return err
+	}
+	job.Status = jobs.StatusRunning
+	if err = s.Jobs.Save(job); err != nil {
+		return err
+	}
+
+	concurrency := s.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var (
+		mu      sync.Mutex // guards job and existing
+		wg      sync.WaitGroup
+		saveErr error
+		sem     = make(chan struct{}, concurrency)
+	)
+	save := func() {
+		if err := s.Jobs.Save(job); err != nil && saveErr == nil {
+			saveErr = err
+		}
+	}
+	for i := range job.Items {
+		item := &job.Items[i]
+		if item.Status == jobs.StatusDone || item.Status == jobs.StatusFailed {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		mu.Lock()
+		if _, ok := existing[item.ID]; ok {
+			// never process, or clean up after, an item that would overwrite a stored photo
+			item.Status = jobs.StatusFailed
+			item.Error = "photo already exists"
+			save()
+			mu.Unlock()
+			<-sem
+			continue
+		}
+		item.Status = jobs.StatusRunning
+		save()
+		itemCopy := *item
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			metadata, err := s.processItem(ctx, &itemCopy)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				// compare against stored photos and earlier items of this job
+				item.Duplicates = photo.Duplicates(existing, item.ID, metadata.Hash, photo.DuplicateThreshold)
+				if len(item.Duplicates) > 0 && job.RejectDuplicates {
+					err = fmt.Errorf("near duplicate of %s", strings.Join(item.Duplicates, ", "))
+				}
+			}
+			if err != nil {
+				log.Print("error processing ", item.ID, ": ", err)
+				s.pipeline().Remove(item.ID) // not in existing, so only objects this item wrote
+				item.Status = jobs.StatusFailed
+				item.Error = err.Error()
+			} else {
+				item.Status = jobs.StatusDone
+				item.Metadata = metadata
+				existing[item.ID] = metadata
+			}
+			save()
+		}()
+	}
+	wg.Wait()
+
+	// save every finished item, including those finished by an earlier run that was cut short,
+	// before giving up on a cancelled context
+	saved, err := s.saveFinishedItems(job)
+	if err != nil {
+		return err
+	}
+	if saveErr != nil {
+		return saveErr
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// staged uploads are the only copy of a photo until its metadata is saved
+	for _, item := range job.Items {
+		if _, ok := saved[item.ID]; ok && item.Source.Upload != "" {
+			if err = s.Storage.Delete(storage.BUCKET_UPLOADS, item.Source.Upload); err != nil {
+				log.Print("error deleting upload: ", err)
+			}
+		}
+	}
+	job.Finish()
+	return s.Jobs.Save(job)
+}
+
+// saveFinishedItems merges the metadata of the job's done items that aren't in photos.json yet, and
+// returns the ids of every done item whose metadata is saved.
+func (s *Server) saveFinishedItems(job *jobs.Job) (map[string]struct{}, error) {
+	stored, err := s.readPhotoMetadata()
+	if err != nil {
+		return nil, err
+	}
+	saved := make(map[string]struct{})
+	metadataMap := make(map[string]photo.Metadata)
+	for _, item := range job.Items {
+		if item.Status != jobs.StatusDone {
+			continue
+		}
+		saved[item.ID] = struct{}{}
+		if _, ok := stored[item.ID]; !ok {
+			metadataMap[item.ID] = item.Metadata
+		}
+	}
+	if len(metadataMap) > 0 {
+		if err = s.savePhotoMetadata(job.Actor, job.IP, metadataMap); err != nil {
+			return nil, err
+		}
+	}
+	return saved, nil
+}
+
+// processItem fetches an item's source to a temp file and runs it through the upload pipeline.
+func (s *Server) processItem(ctx context.Context, item *jobs.Item) (photo.Metadata, error) {
+	if err := item.Metadata.Validate(); err != nil {
+		return item.Metadata, err
+	}
+	var name string
+	switch {
+	case item.Source.URL != "":
+		file, err := photo.GetGooglePhoto(photo.GooglePhotoRequest{
+			Url:      item.Source.URL,
+			Filename: item.Filename,
+			ID:       item.ID,
+		})
+		if err != nil {
+			return item.Metadata, err
+		}
+		file.Close()
+		name = file.Name()
+	case item.Source.Upload != "":
+		r, err := s.Storage.Get(storage.BUCKET_UPLOADS, item.Source.Upload)
+		if err != nil {
+			return item.Metadata, err
+		}
+		defer r.Close()
+		tmp, err := os.CreateTemp("", "upload.*")
+		if err != nil {
+			return item.Metadata, err
+		}
+		_, err = io.Copy(tmp, r)
+		tmp.Close()
+		name = tmp.Name()
+		if err != nil {
+			os.Remove(name)
+			return item.Metadata, err
+		}
+	default:
+		return item.Metadata, fmt.Errorf("item has no source")
+	}
+	defer os.Remove(name)
+	return s.pipeline().Process(ctx, item.ID, name, item.Metadata)
+}
+
+// HandleGetJob returns a job's status and per-item results.
+func (s *Server) HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		httpError(w, "missing id", http.StatusBadRequest)
+		return
+	}
+	job, err := s.Jobs.Get(id)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(job); err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/jobs"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{
+		Storage:     store,
+		Audit:       audit.NewLog(store),
+		Jobs:        jobs.NewStore(store),
+		Concurrency: 1,
+	}
+}
+
+// An earlier run finished an item but was cut short before saving its metadata.
+func TestRunJobSavesItemsFromEarlierRun(t *testing.T) {
+	s := newTestServer(t)
+	if err := s.Storage.Write(storage.BUCKET_UPLOADS, "staged", "image"); err != nil {
+		t.Fatal(err)
+	}
+	job := &jobs.Job{
+		ID:     "job",
+		Status: jobs.StatusRunning,
+		Items: []jobs.Item{{
+			ID:       "p",
+			Source:   jobs.Source{Upload: "staged"},
+			Metadata: photo.Metadata{Filename: "p", Caption: "from the first run"},
+			Status:   jobs.StatusDone,
+		}},
+	}
+	if err := s.Jobs.Save(job); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RunJob(context.Background(), job.ID); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["p"].Caption != "from the first run" {
+		t.Errorf("photos.json = %+v", metadata)
+	}
+	if ok, _ := s.Storage.Exists(storage.BUCKET_UPLOADS, "staged"); ok {
+		t.Error("staged upload not deleted after its metadata was saved")
+	}
+	if job, err = s.Jobs.Get(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != jobs.StatusDone {
+		t.Errorf("job status = %s", job.Status)
+	}
+}
+
+func TestRunJobCancelled(t *testing.T) {
+	s := newTestServer(t)
+	for _, key := range []string{"done", "queued"} {
+		if err := s.Storage.Write(storage.BUCKET_UPLOADS, key, "image"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	job := &jobs.Job{
+		ID: "job",
+		Items: []jobs.Item{
+			{ID: "done", Source: jobs.Source{Upload: "done"}, Metadata: photo.Metadata{Filename: "done"}, Status: jobs.StatusDone},
+			{ID: "queued", Source: jobs.Source{Upload: "queued"}, Status: jobs.StatusQueued},
+		},
+	}
+	if err := s.Jobs.Save(job); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.RunJob(ctx, job.ID); err != context.Canceled {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := metadata["done"]; !ok {
+		t.Error("done item not saved on cancel")
+	}
+	if ok, _ := s.Storage.Exists(storage.BUCKET_UPLOADS, "queued"); !ok {
+		t.Error("upload of an unfinished item deleted")
+	}
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+type PhotoDatum struct {
+	Thumbnail string   `json:"thumbnail"`
+	Image     string   `json:"image"`
+	Srcset    []Source `json:"srcset,omitempty"`
+	photo.Metadata
+}
+
+// Source is one entry of a responsive srcset.
+type Source struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type PhotoList struct {
+	Photos     []PhotoDatum `json:"photos"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
+
+func newPhotoDatum(data photo.Metadata) PhotoDatum {
+	datum := PhotoDatum{
+		Thumbnail: objectURL(storage.BUCKET_THUMBNAILS, data.Filename),
+		Image:     objectURL(storage.BUCKET_IMAGES, data.Filename),
+		Metadata:  data.Public(),
+	}
+	for _, rendition := range data.Renditions {
+		datum.Srcset = append(datum.Srcset, Source{
+			Name:   rendition.Name,
+			URL:    objectURL(photo.RenditionObject(data.Filename, rendition.Name)),
+			Width:  rendition.Width,
+			Height: rendition.Height,
+		})
+	}
+	return datum
+}
+
+func objectURL(bucket, key string) string {
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", bucket, key)
+}
+
+func (s *Server) readPhotoMetadata() (map[string]photo.Metadata, error) {
+	r, err := s.Storage.Get(storage.BUCKET_API, storage.KEY_PHOTOS)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	metadata := make(map[string]photo.Metadata)
+	if err = json.NewDecoder(r).Decode(&metadata); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// listOptions parses /photos/list query parameters:
+// sort (date, filename, uploaded), order (asc, desc), category, tag, album, from, to
+// (YYYY-MM-DD or RFC3339), country, region, cursor and limit.
+func (s *Server) listOptions(r *http.Request) (photo.ListOptions, error) {
+	query := r.URL.Query()
+	opts := photo.ListOptions{
+		Sort:     query.Get("sort"),
+		Category: query.Get("category"),
+		Tag:      query.Get("tag"),
+		Country:  query.Get("country"),
+		Region:   query.Get("region"),
+		Cursor:   query.Get("cursor"),
+	}
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Descending = true
+	default:
+		return opts, fmt.Errorf("invalid order: %s", query.Get("order"))
+	}
+	var err error
+	if limit := query.Get("limit"); limit != "" {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil || opts.Limit < 0 {
+			return opts, fmt.Errorf("invalid limit: %s", limit)
+		}
+	}
+	if opts.From, err = parseDate(query.Get("from")); err != nil {
+		return opts, err
+	}
+	if opts.To, err = parseDate(query.Get("to")); err != nil {
+		return opts, err
+	}
+	if to := query.Get("to"); len(to) == len("2006-01-02") {
+		opts.To = opts.To.AddDate(0, 0, 1) // a bare date includes the whole day
+	}
+	if id := query.Get("album"); id != "" {
+		albums, err := s.readAlbums()
+		if err != nil {
+			return opts, err
+		}
+		album, ok := albums[id]
+		if !ok || album.Visibility != photo.VisibilityPublic {
+			return opts, fmt.Errorf("album not found")
+		}
+		opts.Photos = make(map[string]struct{})
+		for _, name := range album.Photos {
+			opts.Photos[name] = struct{}{}
+		}
+	}
+	return opts, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %s", s)
+	}
+	return t, nil
+}
+
+// HandleListDuplicates returns clusters of photos that are likely duplicates of each other.
+// ?threshold overrides the maximum hash distance (0-64).
+func (s *Server) HandleListDuplicates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	threshold := photo.DuplicateThreshold
+	if t := r.URL.Query().Get("threshold"); t != "" {
+		var err error
+		threshold, err = strconv.Atoi(t)
+		if err != nil || threshold < 0 || threshold > 64 {
+			httpError(w, "invalid threshold", http.StatusBadRequest)
+			return
+		}
+	}
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	clusters := [][]PhotoDatum{}
+	for _, keys := range photo.DuplicateClusters(metadata, threshold) {
+		var cluster []PhotoDatum
+		for _, k := range keys {
+			cluster = append(cluster, newPhotoDatum(metadata[k]))
+		}
+		clusters = append(clusters, cluster)
+	}
+	w.Header().Add("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(clusters); err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleListMissingAltText reports photos without alt text, by filename, so they can be described.
+func (s *Server) HandleListMissingAltText(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var keys []string
+	for k, m := range metadata {
+		if m.MissingAltText() && !m.IsDeleted() {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	photodata := PhotoList{
+		Photos: []PhotoDatum{},
+	}
+	for _, k := range keys {
+		photodata.Photos = append(photodata.Photos, newPhotoDatum(metadata[k]))
+	}
+	w.Header().Add("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(photodata); err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
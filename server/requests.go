@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/email"
+	"github.com/stinkyfingers/chadedwardsapi/request"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+type ModerationRequest struct {
+	ID     string `json:"id"`
+	Action string `json:"action"` // approve or reject
+}
+
+func (s *Server) readRequests() ([]request.Request, error) {
+	r, err := s.Storage.Get(storage.BUCKET_API, storage.KEY_REQUESTS)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var requests []request.Request
+	if err = json.NewDecoder(r).Decode(&requests); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// HandleListAdminRequests returns every stored field of every request, including held and rejected ones.
+func (s *Server) HandleListAdminRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	requests, err := s.readRequests()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if requests == nil {
+		requests = []request.Request{}
+	}
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(requests)
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleListHeldRequests returns requests awaiting moderation.
+func (s *Server) HandleListHeldRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	requests, err := s.readRequests()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	held := []request.Request{}
+	for _, req := range requests {
+		if req.Status == request.StatusHeld {
+			held = append(held, req)
+		}
+	}
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(held)
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleModerateRequest approves or rejects a held request. Approved requests are sent to the band.
+func (s *Server) HandleModerateRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	var modReq ModerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&modReq); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var status string
+	switch modReq.Action {
+	case "approve":
+		status = request.StatusApproved
+	case "reject":
+		status = request.StatusRejected
+	default:
+		httpError(w, "action must be approve or reject", http.StatusBadRequest)
+		return
+	}
+
+	requests, err := s.readRequests()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	index := -1
+	for i, req := range requests {
+		if req.ID == modReq.ID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		httpError(w, "request not found", http.StatusNotFound)
+		return
+	}
+	before := requests[index]
+	if before.Status != request.StatusHeld {
+		httpError(w, "request is not held for review", http.StatusBadRequest)
+		return
+	}
+	requests[index].Status = status
+	if err = s.Storage.Write(storage.BUCKET_API, storage.KEY_REQUESTS, requests); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, audit.ActionRequestModerate, []string{modReq.ID}, map[string]interface{}{modReq.ID: before}, map[string]interface{}{modReq.ID: requests[index]})
+
+	if status == request.StatusApproved {
+		if err = email.SendEmail(requests[index]); err != nil {
+			log.Print("error sending email: ", err)
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(requests[index])
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
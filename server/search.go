@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+type SearchResponse struct {
+	Photos []PhotoDatum              `json:"photos"`
+	Total  int                       `json:"total"`
+	Facets map[string]map[string]int `json:"facets"`
+}
+
+// HandleSearchPhotos searches photo metadata. Query parameters: q (free text over filename, tags,
+// caption, alt text, credit, category and location), category, tag, year and country filters,
+// offset and limit. The response includes facet counts over every match.
+func (s *Server) HandleSearchPhotos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	opts := photo.SearchOptions{
+		Query:    query.Get("q"),
+		Category: query.Get("category"),
+		Tag:      query.Get("tag"),
+		Year:     query.Get("year"),
+		Country:  query.Get("country"),
+	}
+	for name, value := range map[string]*int{"offset": &opts.Offset, "limit": &opts.Limit} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				httpError(w, fmt.Sprintf("invalid %s: %s", name, v), http.StatusBadRequest)
+				return
+			}
+			*value = n
+		}
+	}
+
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	index, err := s.searchIndex(metadata)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result := index.Search(opts)
+	resp := SearchResponse{
+		Photos: []PhotoDatum{},
+		Total:  result.Total,
+		Facets: result.Facets,
+	}
+	for _, key := range result.Photos {
+		resp.Photos = append(resp.Photos, newPhotoDatum(metadata[key]))
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(resp); err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// searchIndex returns an index of metadata: the one in memory, else the persisted one, else a new
+// one, which is persisted for the next cold start. An index is only used if it was built from
+// exactly this metadata, so every writer of photos.json invalidates it.
+func (s *Server) searchIndex(metadata map[string]photo.Metadata) (*photo.SearchIndex, error) {
+	source, err := photo.Fingerprint(metadata)
+	if err != nil {
+		return nil, err
+	}
+	s.searchMu.Lock()
+	defer s.searchMu.Unlock()
+	if s.search != nil && s.search.Fresh(source) {
+		return s.search, nil
+	}
+
+	r, err := s.Storage.Get(storage.BUCKET_API, storage.KEY_SEARCH_INDEX)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if index, err := photo.ReadSearchIndex(r); err == nil && index.Fresh(source) {
+		s.search = index
+		return index, nil
+	}
+
+	index, err := photo.BuildSearchIndex(metadata)
+	if err != nil {
+		return nil, err
+	}
+	s.search = index
+	if err = s.Storage.Write(storage.BUCKET_API, storage.KEY_SEARCH_INDEX, index); err != nil {
+		log.Print("error writing search index: ", err) // rebuilt on the next cold start
+	}
+	return index, nil
+}
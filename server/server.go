@@ -2,24 +2,43 @@ package server
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"strings"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/stinkyfingers/chadedwardsapi/audit"
 	"github.com/stinkyfingers/chadedwardsapi/auth"
+	"github.com/stinkyfingers/chadedwardsapi/challenge"
 	"github.com/stinkyfingers/chadedwardsapi/email"
+	"github.com/stinkyfingers/chadedwardsapi/jobs"
+	"github.com/stinkyfingers/chadedwardsapi/moderation"
 	"github.com/stinkyfingers/chadedwardsapi/photo"
 	"github.com/stinkyfingers/chadedwardsapi/request"
 	"github.com/stinkyfingers/chadedwardsapi/sms"
 	"github.com/stinkyfingers/chadedwardsapi/storage"
+	"github.com/stinkyfingers/chadedwardsapi/uid"
 )
 
 type Server struct {
-	Storage storage.Storage
-	SMS     sms.SMS
+	Storage   storage.Storage
+	SMS       sms.SMS
+	APIKeys   *auth.APIKeys
+	Audit     *audit.Log
+	Challenge *challenge.ProofOfWork
+	Verifier  challenge.Verifier
+	Moderator *moderation.Moderator
+	Jobs      *jobs.Store
+	Queue     jobs.Queue
+	Geocoder  photo.Geocoder
+	Watermark *photo.Watermark // nil disables watermarking
+	// Concurrency is the number of photos a job processes at once.
+	Concurrency int
+
+	searchMu sync.Mutex // guards search
+	search   *photo.SearchIndex
 }
 
 type Suggestion struct {
@@ -28,10 +47,18 @@ type Suggestion struct {
 	Artist  string `json:"artist"`
 }
 
+// PostRequest is the body of POST /request: the song request plus bot-protection fields.
+type PostRequest struct {
+	request.Request
+	Challenge challenge.Submission `json:"challenge"`
+	Website   string               `json:"website"` // honeypot, hidden from humans
+}
+
 type Permission map[string]time.Time // ip:time
 
 var (
-	timeout = time.Minute * 10
+	timeout            = time.Minute * 10
+	defaultConcurrency = 4
 )
 
 func NewServer(profile string) (*Server, error) {
@@ -40,24 +67,84 @@ func NewServer(profile string) (*Server, error) {
 		return nil, err
 	}
 
-	return &Server{
-		Storage: storage,
-		SMS:     sms.NewNexmo(),
-	}, nil
+	pow := challenge.NewProofOfWork(os.Getenv("JWT_KEY"), storage)
+	s := &Server{
+		Storage:     storage,
+		SMS:         sms.NewNexmo(),
+		APIKeys:     auth.NewAPIKeys(storage),
+		Audit:       audit.NewLog(storage),
+		Challenge:   pow,
+		Verifier:    challenge.Chain{challenge.Honeypot{}, pow},
+		Moderator:   moderation.NewModerator(),
+		Jobs:        jobs.NewStore(storage),
+		Concurrency: defaultConcurrency,
+	}
+	if s.Geocoder, err = newGeocoder(storage); err != nil {
+		return nil, err
+	}
+	// a bad watermark shouldn't take the API down; uploads go out unwatermarked until it's fixed
+	if s.Watermark, err = photo.WatermarkFromEnv(storage); err != nil {
+		log.Print("error loading watermark, uploading without one: ", err)
+		s.Watermark = nil
+	}
+	if concurrency, err := strconv.Atoi(os.Getenv("PHOTO_CONCURRENCY")); err == nil && concurrency > 0 {
+		s.Concurrency = concurrency
+	}
+	// jobs run in the worker Lambda when deployed, otherwise in this process
+	if function := os.Getenv("WORKER_FUNCTION"); function != "" {
+		if s.Queue, err = jobs.NewLambdaQueue(profile, function); err != nil {
+			return nil, err
+		}
+	} else {
+		s.Queue = jobs.NewLocalQueue(s.RunJob)
+	}
+	return s, nil
+}
+
+// newGeocoder returns a cached PositionStack geocoder when POSITIONSTACK_KEY is set, otherwise
+// the offline geocoder.
+func newGeocoder(store storage.Storage) (photo.Geocoder, error) {
+	if key := os.Getenv("POSITIONSTACK_KEY"); key != "" {
+		return photo.NewCachedGeocoder(photo.NewPositionStack(key), photo.NewStorageCache(store)), nil
+	}
+	return photo.NewOffline()
 }
 
 // NewMux returns the router
 func NewMux(s *Server) (http.Handler, error) {
-	gcp := &auth.GCP{}
+	authenticator := auth.NewAuthenticator(s.APIKeys)
 	mux := http.NewServeMux()
 	mux.Handle("/requests", cors(s.HandleListRequests))
 	mux.Handle("/request", cors(s.HandlePostRequest))
-	mux.Handle("/auth", cors(gcp.Middleware(status)))            // route to test auth
-	mux.Handle("/test", cors(gcp.Middleware(s.HandleProtected))) // route to test auth
+	mux.Handle("/request/challenge", cors(s.HandleGetChallenge))
+	mux.Handle("/requests/admin", cors(authenticator.Middleware(auth.ScopeRequestsRead, s.HandleListAdminRequests)))
+	mux.Handle("/requests/held", cors(authenticator.Middleware(auth.ScopeRequestsRead, s.HandleListHeldRequests)))
+	mux.Handle("/requests/moderate", cors(authenticator.Middleware(auth.ScopeModerate, s.HandleModerateRequest)))
+	mux.Handle("/auth", cors(authenticator.Middleware("", status)))            // route to test auth
+	mux.Handle("/test", cors(authenticator.Middleware("", s.HandleProtected))) // route to test auth
 	mux.Handle("/photos/list", cors(s.HandleListPhotos))
-	mux.Handle("/photos/update", cors(gcp.Middleware(s.HandleUpdatePhotos)))
-	mux.Handle("/photos/upload", cors(gcp.Middleware(s.HandleUploadPhotos)))
-	mux.Handle("/photos/delete", cors(gcp.Middleware(s.HandleDeletePhoto)))
+	mux.Handle("/photos/search", cors(s.HandleSearchPhotos))
+	mux.Handle("/photos/update", cors(authenticator.Middleware(auth.ScopePhotosWrite, s.HandleUpdatePhotos)))
+	mux.Handle("/photos/upload", cors(authenticator.Middleware(auth.ScopePhotosWrite, s.HandleUploadPhotos)))
+	mux.Handle("/photos/upload/file", cors(authenticator.Middleware(auth.ScopePhotosWrite, s.HandleUploadPhotoFiles)))
+	mux.Handle("/photos/upload/presign", cors(authenticator.Middleware(auth.ScopePhotosWrite, s.HandlePresignUpload)))
+	mux.Handle("/photos/upload/complete", cors(authenticator.Middleware(auth.ScopePhotosWrite, s.HandleCompleteUpload)))
+	mux.Handle("/photos/jobs", cors(authenticator.Middleware(auth.ScopePhotosWrite, s.HandleGetJob)))
+	mux.Handle("/photos/delete", cors(authenticator.Middleware(auth.ScopePhotosWrite, s.HandleDeletePhoto)))
+	mux.Handle("/photos/trash", cors(authenticator.Middleware(auth.ScopePhotosRead, s.HandleListTrash)))
+	mux.Handle("/photos/restore", cors(authenticator.Middleware(auth.ScopePhotosWrite, s.HandleRestorePhoto)))
+	mux.Handle("/photos/duplicates", cors(authenticator.Middleware(auth.ScopePhotosRead, s.HandleListDuplicates)))
+	mux.Handle("/photos/missing-alt", cors(authenticator.Middleware(auth.ScopePhotosRead, s.HandleListMissingAltText)))
+	mux.Handle("/albums/list", cors(s.HandleListAlbums))
+	mux.Handle("/albums/get", cors(s.HandleGetAlbum))
+	mux.Handle("/albums/admin", cors(authenticator.Middleware(auth.ScopePhotosRead, s.HandleListAdminAlbums)))
+	mux.Handle("/albums/create", cors(authenticator.Middleware(auth.ScopePhotosWrite, s.HandleCreateAlbum)))
+	mux.Handle("/albums/update", cors(authenticator.Middleware(auth.ScopePhotosWrite, s.HandleUpdateAlbum)))
+	mux.Handle("/albums/delete", cors(authenticator.Middleware(auth.ScopePhotosWrite, s.HandleDeleteAlbum)))
+	mux.Handle("/apikeys/list", cors(authenticator.Middleware(auth.ScopeAdmin, s.HandleListAPIKeys)))
+	mux.Handle("/apikeys/create", cors(authenticator.Middleware(auth.ScopeAdmin, s.HandleCreateAPIKey)))
+	mux.Handle("/apikeys/revoke", cors(authenticator.Middleware(auth.ScopeAdmin, s.HandleRevokeAPIKey)))
+	mux.Handle("/audit", cors(authenticator.Middleware(auth.ScopeOwner, s.HandleListAudit)))
 	mux.Handle("/health", cors(status))
 	return mux, nil
 }
@@ -110,14 +197,20 @@ func (s *Server) HandleListRequests(w http.ResponseWriter, r *http.Request) {
 		httpError(w, "invalid method", http.StatusBadRequest)
 		return
 	}
-	requests, err := s.Storage.Read(storage.BUCKET_API, storage.KEY_REQUESTS)
+	requests, err := s.readRequests()
 	if err != nil {
 		log.Print("error reading requests: ", err)
 		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	visible := []request.Public{}
+	for _, req := range requests {
+		if req.Visible() {
+			visible = append(visible, req.Public())
+		}
+	}
 	w.Header().Add("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(requests)
+	err = json.NewEncoder(w).Encode(visible)
 	if err != nil {
 		log.Print("error encoding response: ", err)
 		httpError(w, err.Error(), http.StatusInternalServerError)
@@ -131,16 +224,40 @@ func (s *Server) HandlePostRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req request.Request
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	var postReq PostRequest
+	if err := json.NewDecoder(r.Body).Decode(&postReq); err != nil {
 		httpError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req := postReq.Request
 	if req.Song == "" || req.Artist == "" {
 		httpError(w, "song and artist required", http.StatusBadRequest)
 		return
 	}
+	result, err := s.Moderator.Moderate(&req)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// verify last: it spends the challenge, so a request rejected above can be fixed and resubmitted
+	submission := postReq.Challenge
+	submission.Honeypot = postReq.Website
+	submission.RemoteIP = clientIP(r)
+	if err = s.Verifier.Verify(r.Context(), submission); err != nil {
+		log.Print("error verifying challenge: ", err)
+		httpError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if req.ID, err = uid.New(); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	req.Time = time.Now()
+	req.Status = request.StatusApproved
+	req.Flags = result.Reasons
+	if result.Flagged {
+		req.Status = request.StatusHeld
+	}
 	if err := s.Storage.CheckPermission(req.Session); err != nil {
 		log.Print("error checking permission: ", err)
 		httpError(w, err.Error(), http.StatusForbidden)
@@ -160,13 +277,35 @@ func (s *Server) HandlePostRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := email.SendEmail(req); err != nil {
-		log.Print("error sending email: ", err)
+	if req.Status == request.StatusApproved {
+		if err := email.SendEmail(req); err != nil {
+			log.Print("error sending email: ", err)
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(req.Public())
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleGetChallenge issues a challenge that must be solved before POST /request.
+func (s *Server) HandleGetChallenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	c, err := s.Challenge.Issue()
+	if err != nil {
 		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Add("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(req)
+	err = json.NewEncoder(w).Encode(c)
 	if err != nil {
 		log.Print("error encoding response: ", err)
 		httpError(w, err.Error(), http.StatusInternalServerError)
@@ -205,34 +344,45 @@ func (s *Server) HandleRequest(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HandleListPhotos returns a page of photos as a PhotoList when the limit or cursor query
+// parameter is given, and otherwise every matching photo as an array, as it did before pagination.
+// See listOptions for the other query parameters.
 func (s *Server) HandleListPhotos(w http.ResponseWriter, r *http.Request) {
-	reader, err := s.Storage.Get(storage.BUCKET_API, storage.KEY_PHOTOS)
+	opts, err := s.listOptions(r)
 	if err != nil {
-		httpError(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	var metadata map[string]photo.Metadata
-	if err = json.NewDecoder(reader).Decode(&metadata); err != nil {
+	query := r.URL.Query()
+	paginated := query.Has("limit") || query.Has("cursor")
+	if !paginated {
+		opts.Limit = photo.NoLimit
+	}
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
 		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	page, next, err := photo.List(metadata, opts)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	type PhotoDatum struct {
-		Thumbnail string `json:"thumbnail"`
-		Image     string `json:"image"`
-		photo.Metadata
+	photodata := PhotoList{
+		Photos:     []PhotoDatum{},
+		NextCursor: next,
 	}
-	var photodata []PhotoDatum
-	for _, data := range metadata {
-		photodata = append(photodata, PhotoDatum{
-			Thumbnail: fmt.Sprintf("https://%s.s3.amazonaws.com/%s", storage.BUCKET_THUMBNAILS, data.Filename),
-			Image:     fmt.Sprintf("https://%s.s3.amazonaws.com/%s", storage.BUCKET_IMAGES, data.Filename),
-			Metadata:  data,
-		})
+	for _, data := range page {
+		photodata.Photos = append(photodata.Photos, newPhotoDatum(data))
 	}
 
 	w.Header().Add("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(photodata)
+	if paginated {
+		err = json.NewEncoder(w).Encode(photodata)
+	} else {
+		err = json.NewEncoder(w).Encode(photodata.Photos)
+	}
 	if err != nil {
 		log.Print("error encoding response: ", err)
 		httpError(w, err.Error(), http.StatusInternalServerError)
@@ -240,6 +390,8 @@ func (s *Server) HandleListPhotos(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HandleUpdatePhotos applies the user-editable fields of each submitted photo, keyed by filename, to its stored
+// metadata. See photo.Metadata.Edit.
 func (s *Server) HandleUpdatePhotos(w http.ResponseWriter, r *http.Request) {
 	var photoMetadata map[string]photo.Metadata
 	err := json.NewDecoder(r.Body).Decode(&photoMetadata)
@@ -247,6 +399,12 @@ func (s *Server) HandleUpdatePhotos(w http.ResponseWriter, r *http.Request) {
 		httpError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	for k, v := range photoMetadata {
+		if err = v.Validate(); err != nil {
+			httpError(w, k+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 	reader, err := s.Storage.Get(storage.BUCKET_API, storage.KEY_PHOTOS)
 	if err != nil {
 		httpError(w, err.Error(), http.StatusInternalServerError)
@@ -258,15 +416,32 @@ func (s *Server) HandleUpdatePhotos(w http.ResponseWriter, r *http.Request) {
 		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	for k := range photoMetadata {
+		if _, ok := metadata[k]; !ok {
+			httpError(w, "photo not found: "+k, http.StatusNotFound)
+			return
+		}
+	}
+	targets := make([]string, 0, len(photoMetadata))
+	before := make(map[string]interface{})
+	after := make(map[string]interface{})
+	updated := make(map[string]photo.Metadata)
 	for k, v := range photoMetadata {
-		metadata[k] = v
+		// clients send back what they listed, which may be a Public projection; only editable fields are taken
+		existing := metadata[k]
+		before[k] = existing
+		updated[k] = existing.Edit(v)
+		after[k] = updated[k]
+		targets = append(targets, k)
+		metadata[k] = updated[k]
 	}
 	if err = s.Storage.Write(storage.BUCKET_API, storage.KEY_PHOTOS, metadata); err != nil {
 		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, audit.ActionPhotosUpdate, targets, before, after)
 	w.Header().Add("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(photoMetadata)
+	err = json.NewEncoder(w).Encode(updated)
 	if err != nil {
 		log.Print("error encoding response: ", err)
 		httpError(w, err.Error(), http.StatusInternalServerError)
@@ -274,6 +449,8 @@ func (s *Server) HandleUpdatePhotos(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HandleUploadPhotos queues a job to download and process Google Photos. Poll /photos/jobs for progress.
+// Near-duplicates of existing photos are listed on each item, or rejected with ?duplicates=reject.
 func (s *Server) HandleUploadPhotos(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		httpError(w, "invalid method", http.StatusBadRequest)
@@ -286,95 +463,32 @@ func (s *Server) HandleUploadPhotos(w http.ResponseWriter, r *http.Request) {
 		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	metadataMap := make(map[string]photo.Metadata)
-	revertFunc := func() { // func to un-write these images & thumbnails
-		for _, photoRequest := range photoRequests {
-			s.Storage.Delete(storage.BUCKET_IMAGES, photoRequest.ID)
-			s.Storage.Delete(storage.BUCKET_THUMBNAILS, photoRequest.ID)
-		}
-	}
+	var items []jobs.Item
 	for _, photoRequest := range photoRequests {
 		if photoRequest.ID == "" {
-			revertFunc()
-			httpError(w, "no photo filename provided", http.StatusInternalServerError)
+			httpError(w, "no photo filename provided", http.StatusBadRequest)
 			return
 		}
-		file, err := photo.GetGooglePhoto(photoRequest)
-		if err != nil {
-			revertFunc()
-			httpError(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer os.Remove(file.Name())
-		if photoRequest.MimeType != "image/jpeg" {
-			revertFunc()
-			httpError(w, "invalid mime type", http.StatusBadRequest)
-			return
-		}
-		// TODO enable png if/when we get converter below working
-		if !strings.HasSuffix(strings.ToLower(photoRequest.Filename), ".jpg") && !strings.HasSuffix(strings.ToLower(photoRequest.Filename), ".jpeg") {
-			revertFunc()
-			httpError(w, "invalid file extension", http.StatusBadRequest)
-			return
-		}
-
-		if strings.HasSuffix(strings.ToLower(photoRequest.Filename), ".png") {
-			err = photo.PngToJpg(file.Name(), file) // TODO not working
-			if err != nil {
-				revertFunc()
-				httpError(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-		}
-		// thumbnail
-		thumbnail, err := photo.CreateThumbnail(file.Name())
-		if err != nil {
-			log.Println("error creating thumbnail: ", err)
-		}
-		defer os.Remove(thumbnail)
-		if err = s.Storage.Upload(storage.BUCKET_THUMBNAILS, photoRequest.ID, thumbnail); err != nil {
-			revertFunc()
-			httpError(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		if err = s.Storage.Upload(storage.BUCKET_IMAGES, photoRequest.ID, file.Name()); err != nil {
-			revertFunc()
-			httpError(w, err.Error(), http.StatusInternalServerError)
+		if err = photoRequest.Metadata.Validate(); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		photoRequest.Metadata.Filename = photoRequest.ID
-		metadataMap[photoRequest.ID] = photoRequest.Metadata
-	}
-	// update custom metadata
-	res, err := s.Storage.Get(storage.BUCKET_API, storage.KEY_PHOTOS)
-	if err != nil {
-		revertFunc()
-		httpError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	var metadata map[string]photo.Metadata
-	err = json.NewDecoder(res).Decode(&metadata)
-	if err != nil {
-		revertFunc()
-		httpError(w, err.Error(), http.StatusInternalServerError)
-		return
+		items = append(items, jobs.Item{
+			ID:       photoRequest.ID,
+			Filename: photoRequest.Filename,
+			Source:   jobs.Source{URL: photoRequest.Url},
+			Metadata: photoRequest.Metadata,
+		})
 	}
-	photo.UpdateMetadata(metadataMap, metadata)
-	if err = s.Storage.Write(storage.BUCKET_API, storage.KEY_PHOTOS, metadata); err != nil {
-		revertFunc()
-		httpError(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Add("Content-Type", "application/json")
-	if err = json.NewEncoder(w).Encode(photoRequests); err != nil {
-		revertFunc()
-		httpError(w, err.Error(), http.StatusInternalServerError)
+	if len(items) == 0 {
+		httpError(w, "no photos provided", http.StatusBadRequest)
 		return
 	}
+	s.enqueueJob(w, r, items)
 }
 
+// HandleDeletePhoto moves a photo's objects to the trash, marks its metadata deleted and removes it
+// from albums. It can be restored with /photos/restore until it is purged.
 func (s *Server) HandleDeletePhoto(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "DELETE" {
 		httpError(w, "invalid method", http.StatusBadRequest)
@@ -386,30 +500,28 @@ func (s *Server) HandleDeletePhoto(w http.ResponseWriter, r *http.Request) {
 		httpError(w, "missing name", http.StatusBadRequest)
 		return
 	}
-	if err := s.Storage.Delete(storage.BUCKET_IMAGES, name); err != nil {
-		httpError(w, "missing name", http.StatusBadRequest)
-		return
-	}
-	if err := s.Storage.Delete(storage.BUCKET_THUMBNAILS, name); err != nil {
-		httpError(w, "missing name", http.StatusBadRequest)
-		return
-	}
-	resp, err := s.Storage.Get(storage.BUCKET_API, storage.KEY_PHOTOS)
+	metadata, err := s.readPhotoMetadata()
 	if err != nil {
 		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	var metadata map[string]photo.Metadata
-	err = json.NewDecoder(resp).Decode(&metadata)
-	if err != nil {
+	datum, ok := metadata[name]
+	if !ok || datum.IsDeleted() {
+		httpError(w, "photo not found", http.StatusNotFound)
+		return
+	}
+	if err = photo.MoveToTrash(s.Storage, name, datum); err != nil {
 		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	delete(metadata, name)
+	before := map[string]interface{}{name: datum}
+	datum.Deleted = time.Now()
+	metadata[name] = datum
 	if err = s.Storage.Write(storage.BUCKET_API, storage.KEY_PHOTOS, metadata); err != nil {
 		httpError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, audit.ActionPhotoDelete, []string{name}, before, map[string]interface{}{name: datum})
 	httpSuccess(w)
 }
 
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stinkyfingers/chadedwardsapi/challenge"
+	"github.com/stinkyfingers/chadedwardsapi/moderation"
+)
+
+type countingVerifier struct {
+	calls int
+}
+
+func (v *countingVerifier) Verify(ctx context.Context, submission challenge.Submission) error {
+	v.calls++
+	return nil
+}
+
+// A request rejected by validation or moderation mustn't spend the visitor's challenge.
+func TestHandlePostRequestInvalidKeepsChallenge(t *testing.T) {
+	s := newTestServer(t)
+	verifier := &countingVerifier{}
+	s.Verifier = verifier
+	s.Moderator = &moderation.Moderator{MaxName: 5, MaxMessage: 10, MaxSong: 10}
+
+	for _, body := range []string{
+		`{"song":"","artist":"Band"}`,
+		`{"song":"Song","artist":"Band","name":"much too long"}`,
+		`{"song":"a song title far too long","artist":"Band"}`,
+	} {
+		w := httptest.NewRecorder()
+		s.HandlePostRequest(w, httptest.NewRequest("POST", "/request", strings.NewReader(body)))
+		if !strings.Contains(w.Body.String(), `"code":400`) {
+			t.Errorf("%s: response = %s, want a 400 error", body, w.Body)
+		}
+	}
+	if verifier.calls != 0 {
+		t.Errorf("challenge verified %d times for invalid requests", verifier.calls)
+	}
+}
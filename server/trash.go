@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+)
+
+// HandleListTrash returns deleted photos, most recently deleted first.
+func (s *Server) HandleListTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	trash := []photo.Metadata{}
+	for _, m := range metadata {
+		if m.IsDeleted() {
+			trash = append(trash, m)
+		}
+	}
+	sort.Slice(trash, func(i, j int) bool {
+		return trash[i].Deleted.After(trash[j].Deleted)
+	})
+	w.Header().Add("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(trash); err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleRestorePhoto moves a deleted photo's objects back from the trash. Deleted photos stay in
+// their albums, hidden until restored.
+func (s *Server) HandleRestorePhoto(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		httpError(w, "missing name", http.StatusBadRequest)
+		return
+	}
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	datum, ok := metadata[name]
+	if !ok || !datum.IsDeleted() {
+		httpError(w, "photo not in trash", http.StatusNotFound)
+		return
+	}
+	if err = photo.RestoreFromTrash(s.Storage, name, datum); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	before := map[string]interface{}{name: datum}
+	datum.Deleted = time.Time{}
+	metadata[name] = datum
+	if err = s.Storage.Write(storage.BUCKET_API, storage.KEY_PHOTOS, metadata); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, audit.ActionPhotoRestore, []string{name}, before, map[string]interface{}{name: datum})
+	httpSuccess(w)
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/stinkyfingers/chadedwardsapi/audit"
+	"github.com/stinkyfingers/chadedwardsapi/jobs"
+	"github.com/stinkyfingers/chadedwardsapi/photo"
+	"github.com/stinkyfingers/chadedwardsapi/storage"
+	"github.com/stinkyfingers/chadedwardsapi/uid"
+)
+
+const (
+	maxUploadSize   = 50 << 20 // multipart request body
+	maxMemory       = 8 << 20
+	presignDuration = time.Minute * 15
+)
+
+type PresignRequest struct {
+	Filename string `json:"filename"`
+}
+
+type PresignResponse struct {
+	ID      string    `json:"id"`
+	URL     string    `json:"url"` // PUT the file body here
+	Expires time.Time `json:"expires"`
+}
+
+// presignedUpload records an id issued by HandlePresignUpload, so that HandleCompleteUpload only
+// queues ids the server created.
+type presignedUpload struct {
+	ID      string    `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+type CompleteUploadRequest struct {
+	ID       string         `json:"id"`
+	Metadata photo.Metadata `json:"metadata"`
+}
+
+// pipeline returns the photo pipeline configured for this server.
+func (s *Server) pipeline() *photo.Pipeline {
+	return &photo.Pipeline{
+		Storage:   s.Storage,
+		Geocoder:  s.Geocoder,
+		Watermark: s.Watermark,
+	}
+}
+
+// savePhotoMetadata merges uploaded metadata into photos.json and audits the upload on behalf of actor.
+func (s *Server) savePhotoMetadata(actor, ip string, metadataMap map[string]photo.Metadata) error {
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
+		return err
+	}
+	targets := make([]string, 0, len(metadataMap))
+	before := make(map[string]interface{})
+	after := make(map[string]interface{})
+	for k, v := range metadataMap {
+		if existing, ok := metadata[k]; ok {
+			before[k] = existing
+		}
+		after[k] = v
+		targets = append(targets, k)
+	}
+	photo.UpdateMetadata(metadataMap, metadata)
+	if err = s.Storage.Write(storage.BUCKET_API, storage.KEY_PHOTOS, metadata); err != nil {
+		return err
+	}
+	s.auditAs(actor, ip, audit.ActionPhotosUpload, targets, before, after)
+	return nil
+}
+
+// HandleUploadPhotoFiles accepts multipart/form-data with one or more "photos" file parts and an
+// optional "metadata" part holding photo.Metadata JSON applied to every file. Files are staged in
+// the uploads bucket and processed by a job.
+func (s *Server) HandleUploadPhotoFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	var base photo.Metadata
+	if m := r.FormValue("metadata"); m != "" {
+		if err := json.Unmarshal([]byte(m), &base); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := base.Validate(); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	headers := r.MultipartForm.File["photos"]
+	if len(headers) == 0 {
+		httpError(w, "no photos provided", http.StatusBadRequest)
+		return
+	}
+
+	var items []jobs.Item
+	for _, header := range headers {
+		id, err := uid.New()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err = s.stagePart(id, header); err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		items = append(items, jobs.Item{
+			ID:       id,
+			Filename: header.Filename,
+			Source:   jobs.Source{Upload: id},
+			Metadata: base,
+		})
+	}
+	s.enqueueJob(w, r, items)
+}
+
+// stagePart copies a multipart file to the uploads bucket.
+func (s *Server) stagePart(id string, header *multipart.FileHeader) error {
+	src, err := header.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp("", "upload.*"+filepath.Ext(header.Filename))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, src)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+	return s.Storage.UploadPrivate(storage.BUCKET_UPLOADS, id, tmp.Name())
+}
+
+// HandlePresignUpload returns a presigned URL for uploading a large file directly to S3,
+// bypassing the Lambda request size limit. Call /photos/upload/complete once the PUT succeeds.
+func (s *Server) HandlePresignUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	var req PresignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id, err := uid.New()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	url, err := s.Storage.PresignUpload(storage.BUCKET_UPLOADS, id, presignDuration)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	upload := presignedUpload{
+		ID:      id,
+		Expires: time.Now().Add(presignDuration),
+	}
+	if err = s.Storage.Write(storage.BUCKET_API, storage.KEY_UPLOAD_PREFIX+id, upload); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(PresignResponse{
+		ID:      id,
+		URL:     url,
+		Expires: upload.Expires,
+	})
+	if err != nil {
+		log.Print("error encoding response: ", err)
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleCompleteUpload queues a file previously PUT to a presigned URL for processing. Only ids
+// issued by HandlePresignUpload, whose file has been uploaded, are accepted, and each only once.
+func (s *Server) HandleCompleteUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		httpError(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	var req CompleteUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" {
+		httpError(w, "missing id", http.StatusBadRequest)
+		return
+	}
+	if !uid.Valid(req.ID) {
+		httpError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if err := req.Metadata.Validate(); err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	upload, err := s.readPresignedUpload(req.ID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if upload == nil || upload.ID != req.ID {
+		httpError(w, "unknown upload id", http.StatusBadRequest)
+		return
+	}
+	// the presigned URL expires at upload.Expires, so a file in the bucket was PUT before then
+	ok, err := s.Storage.Exists(storage.BUCKET_UPLOADS, req.ID)
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		httpError(w, "file not uploaded", http.StatusBadRequest)
+		return
+	}
+	metadata, err := s.readPhotoMetadata()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, ok := metadata[req.ID]; ok {
+		httpError(w, "photo already exists", http.StatusConflict)
+		return
+	}
+	if err = s.Storage.Delete(storage.BUCKET_API, storage.KEY_UPLOAD_PREFIX+req.ID); err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.enqueueJob(w, r, []jobs.Item{{
+		ID:       req.ID,
+		Filename: req.ID,
+		Source:   jobs.Source{Upload: req.ID},
+		Metadata: req.Metadata,
+	}})
+}
+
+// readPresignedUpload returns the record of presigned upload id, or nil if there is none.
+func (s *Server) readPresignedUpload(id string) (*presignedUpload, error) {
+	r, err := s.Storage.Get(storage.BUCKET_API, storage.KEY_UPLOAD_PREFIX+id)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var upload presignedUpload
+	if err = json.NewDecoder(r).Decode(&upload); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &upload, nil
+}
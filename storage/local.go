@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Local stores objects as files under Root/<bucket>/<key>, for development and for working on a
+// copy of the buckets offline. Like S3, reading a missing key is not an error.
+type Local struct {
+	Root string
+}
+
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{
+		Root: root,
+	}, nil
+}
+
+func (l *Local) path(bucket, key string) string {
+	return filepath.Join(l.Root, bucket, filepath.FromSlash(key))
+}
+
+func (l *Local) Write(bucket, key string, object obj) error {
+	j, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	return l.writeFile(bucket, key, bytes.NewReader(j))
+}
+
+func (l *Local) Read(bucket, key string) ([]obj, error) {
+	r, err := l.Get(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	objects := []obj{}
+	if err = json.NewDecoder(r).Decode(&objects); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return objects, nil
+}
+
+func (l *Local) Get(bucket, key string) (io.ReadCloser, error) {
+	f, err := os.Open(l.path(bucket, key))
+	if os.IsNotExist(err) {
+		return io.NopCloser(&bytes.Buffer{}), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (l *Local) Exists(bucket, key string) (bool, error) {
+	_, err := os.Stat(l.path(bucket, key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *Local) List(bucket string) ([]string, error) {
+	var keys []string
+	dir := filepath.Join(l.Root, bucket)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() || strings.HasPrefix(info.Name(), ".tmp-") {
+			return err
+		}
+		key, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, filepath.ToSlash(key))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (l *Local) ListPrefix(bucket, prefix string) ([]string, error) {
+	keys, err := l.List(bucket)
+	if err != nil {
+		return nil, err
+	}
+	var matched []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			matched = append(matched, key)
+		}
+	}
+	return matched, nil
+}
+
+func (l *Local) Delete(bucket, key string) error {
+	err := os.Remove(l.path(bucket, key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Upload copies a file into bucket. Local files have no ACL, so it is the same as UploadPrivate.
+func (l *Local) Upload(bucket, key, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return l.writeFile(bucket, key, f)
+}
+
+func (l *Local) UploadPrivate(bucket, key, filename string) error {
+	return l.Upload(bucket, key, filename)
+}
+
+// Copy copies an object. Like Get, a missing source is not an error.
+func (l *Local) Copy(srcBucket, srcKey, bucket, key string) error {
+	f, err := os.Open(l.path(srcBucket, srcKey))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return l.writeFile(bucket, key, f)
+}
+
+func (l *Local) CopyPrivate(srcBucket, srcKey, bucket, key string) error {
+	return l.Copy(srcBucket, srcKey, bucket, key)
+}
+
+func (l *Local) PresignUpload(bucket, key string, expires time.Duration) (string, error) {
+	return "", fmt.Errorf("presigned uploads are not supported by local storage")
+}
+
+func (l *Local) CheckPermission(session string) error {
+	return nil
+}
+
+// writeFile writes through a temp file so that readers never see a partial object.
+func (l *Local) writeFile(bucket, key string, r io.Reader) error {
+	name := l.path(bucket, key)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
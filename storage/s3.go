@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -24,9 +25,22 @@ const (
 	BUCKET_API        = "chadedwardsapi"
 	BUCKET_IMAGES     = "chadedwardsbandimages"
 	BUCKET_THUMBNAILS = "chadedwardsbandthumbnails"
+	BUCKET_RENDITIONS = "chadedwardsbandrenditions"
+	BUCKET_ORIGINALS  = "chadedwardsbandoriginals" // private, unmodified uploads
+	BUCKET_UPLOADS    = "chadedwardsbanduploads"   // private, presigned uploads awaiting processing
 	blacklistKey      = "session-blacklist"
 	KEY_REQUESTS      = "requests"
 	KEY_PHOTOS        = "photos.json"
+	KEY_API_KEYS      = "apikeys.json"
+	KEY_LAST_USED     = "apikeys/last-used/" // one object per api key id, so Authorize never rewrites apikeys.json
+	KEY_AUDIT_PREFIX  = "audit/"
+	KEY_ALBUMS        = "albums.json"
+	KEY_JOBS_PREFIX   = "jobs/"
+	KEY_GEOCODE_CACHE = "geocode-cache.json"
+	KEY_SEARCH_INDEX  = "search-index.json"
+	KEY_TRASH_PREFIX  = "trash/"      // in BUCKET_ORIGINALS
+	KEY_UPLOAD_PREFIX = "uploads/"    // presigned upload ids awaiting /photos/upload/complete
+	KEY_NONCE_PREFIX  = "challenges/" // proof of work nonces already used, expired by a bucket lifecycle rule
 )
 
 func NewS3(profile string) (*S3, error) {
@@ -100,10 +114,32 @@ func (s *S3) Get(bucket, key string) (io.ReadCloser, error) {
 	return resp.Body, nil
 }
 
+// Exists reports whether an object is stored at key.
+func (s *S3) Exists(bucket, key string) (bool, error) {
+	_, err := s.Session.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		// HEAD responses have no body, so a missing key is reported as NotFound rather than NoSuchKey
+		if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == "NotFound" || aerr.Code() == s3.ErrCodeNoSuchKey) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 func (s *S3) List(bucket string) ([]string, error) {
+	return s.ListPrefix(bucket, "")
+}
+
+// ListPrefix returns the keys in bucket that start with prefix.
+func (s *S3) ListPrefix(bucket, prefix string) ([]string, error) {
 	var keys []string
 	err := s.Session.ListObjectsV2Pages(&s3.ListObjectsV2Input{
 		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
 	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
 		for _, o := range page.Contents {
 			keys = append(keys, *o.Key)
@@ -116,7 +152,17 @@ func (s *S3) List(bucket string) ([]string, error) {
 	return keys, nil
 }
 
+// Upload uploads a publicly readable file.
 func (s *S3) Upload(bucket, key, filename string) error {
+	return s.upload(bucket, key, filename, s3.ObjectCannedACLPublicRead)
+}
+
+// UploadPrivate uploads a file readable only with bucket credentials.
+func (s *S3) UploadPrivate(bucket, key, filename string) error {
+	return s.upload(bucket, key, filename, s3.ObjectCannedACLPrivate)
+}
+
+func (s *S3) upload(bucket, key, filename, acl string) error {
 	f, err := os.Open(filename)
 	if err != nil {
 		return err
@@ -135,11 +181,43 @@ func (s *S3) Upload(bucket, key, filename string) error {
 		Body:          bytes.NewReader(buffer),
 		ContentLength: aws.Int64(info.Size()),
 		ContentType:   aws.String(fileType), // e.g. image/jpeg
-		ACL:           aws.String("public-read"),
+		ACL:           aws.String(acl),
 	})
 	return err
 }
 
+// Copy copies an object, publicly readable. Like Get, a missing source is not an error.
+func (s *S3) Copy(srcBucket, srcKey, bucket, key string) error {
+	return s.copy(srcBucket, srcKey, bucket, key, s3.ObjectCannedACLPublicRead)
+}
+
+// CopyPrivate copies an object, readable only with bucket credentials.
+func (s *S3) CopyPrivate(srcBucket, srcKey, bucket, key string) error {
+	return s.copy(srcBucket, srcKey, bucket, key, s3.ObjectCannedACLPrivate)
+}
+
+func (s *S3) copy(srcBucket, srcKey, bucket, key, acl string) error {
+	_, err := s.Session.CopyObject(&s3.CopyObjectInput{
+		CopySource: aws.String(url.PathEscape(srcBucket + "/" + srcKey)),
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		ACL:        aws.String(acl),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil
+	}
+	return err
+}
+
+// PresignUpload returns a URL that accepts a PUT of the object body until expires elapses.
+func (s *S3) PresignUpload(bucket, key string, expires time.Duration) (string, error) {
+	req, _ := s.Session.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return req.Presign(expires)
+}
+
 func (s *S3) Delete(bucket, key string) error {
 	_, err := s.Session.DeleteObject(&s3.DeleteObjectInput{
 		Bucket: aws.String(bucket),
@@ -168,9 +246,13 @@ func (s *S3) CheckPermission(session string) error {
 			return err
 		}
 	}
-	for session, timestamp := range permissions {
-		if session == session && time.Now().Add(-1*timeout).Before(timestamp) {
-			return fmt.Errorf("permission denied: you must wait 10 minutes before requesting again")
+	cutoff := time.Now().Add(-1 * timeout)
+	if timestamp, ok := permissions[session]; ok && cutoff.Before(timestamp) {
+		return fmt.Errorf("permission denied: you must wait 10 minutes before requesting again")
+	}
+	for other, timestamp := range permissions {
+		if !cutoff.Before(timestamp) {
+			delete(permissions, other)
 		}
 	}
 
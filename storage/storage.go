@@ -9,9 +9,15 @@ type Storage interface {
 	Write(bucket, key string, o obj) error
 	Read(bucket, key string) ([]obj, error)
 	Get(bucket, key string) (io.ReadCloser, error)
+	Exists(bucket, key string) (bool, error)
 	List(bucket string) ([]string, error)
+	ListPrefix(bucket, prefix string) ([]string, error)
 	Delete(bucket, key string) error
 	Upload(bucket, key, filename string) error
+	UploadPrivate(bucket, key, filename string) error
+	Copy(srcBucket, srcKey, bucket, key string) error
+	CopyPrivate(srcBucket, srcKey, bucket, key string) error
+	PresignUpload(bucket, key string, expires time.Duration) (string, error)
 	CheckPermission(session string) error
 }
 
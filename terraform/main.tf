@@ -74,6 +74,31 @@ variable "positionstack_key" {
   default = "/chadedwardsapi/positionstack_key"
 }
 
+variable "owner_emails" {
+  type    = string
+  default = "/chadedwardsapi/owner_emails"
+}
+
+variable "admin_emails" {
+  type    = string
+  default = "/chadedwardsapi/admin_emails"
+}
+
+variable "moderation_words" {
+  type    = string
+  default = "/chadedwardsapi/moderation_words"
+}
+
+variable "watermark_logo" {
+  type    = string
+  default = "" # key of a logo image in the api bucket
+}
+
+variable "watermark_text" {
+  type    = string
+  default = ""
+}
+
 # provider
 terraform {
   required_providers {
@@ -147,6 +172,28 @@ resource "aws_lambda_function" "server" {
       GMAIL_DESTINATION  = data.aws_ssm_parameter.gmail_destination.value
       JWT_KEY            = data.aws_ssm_parameter.jwt_key.value
       POSITIONSTACK_KEY  = data.aws_ssm_parameter.positionstack_key.value
+      OWNER_EMAILS       = data.aws_ssm_parameter.owner_emails.value
+      ADMIN_EMAILS       = data.aws_ssm_parameter.admin_emails.value
+      MODERATION_WORDS   = data.aws_ssm_parameter.moderation_words.value
+      WORKER_FUNCTION    = aws_lambda_function.worker.function_name
+    }
+  }
+}
+
+resource "aws_lambda_function" "worker" {
+  filename         = "../worker.zip"
+  function_name    = "chadedwardsapi-worker"
+  role             = aws_iam_role.lambda_role.arn
+  handler          = "worker-lambda"
+  runtime          = "go1.x"
+  source_code_hash = filebase64sha256("../worker.zip")
+  timeout          = 300
+  memory_size      = 1024
+  environment {
+    variables = {
+      POSITIONSTACK_KEY = data.aws_ssm_parameter.positionstack_key.value
+      WATERMARK_LOGO    = var.watermark_logo
+      WATERMARK_TEXT    = var.watermark_text
     }
   }
 }
@@ -219,6 +266,30 @@ resource "aws_iam_policy" "ssm-policy" {
 EOF
 }
 
+resource "aws_iam_role_policy_attachment" "invoke-policy-attach" {
+  role       = aws_iam_role.lambda_role.name
+  policy_arn = aws_iam_policy.invoke-policy.arn
+}
+
+resource "aws_iam_policy" "invoke-policy" {
+  name        = "chadedwardsapi-lambda-invoke-policy"
+  description = "Grants lambda access to invoke the worker"
+  policy      = <<EOF
+{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": [
+        "lambda:InvokeFunction"
+      ],
+      "Resource": "arn:aws:lambda:*:*:function:chadedwardsapi-worker"
+    }
+  ]
+}
+EOF
+}
+
 resource "aws_iam_role_policy_attachment" "s3-policy-attach" {
   role       = aws_iam_role.lambda_role.name
   policy_arn = aws_iam_policy.s3-policy.arn
@@ -254,6 +325,28 @@ resource "aws_lb_listener_rule" "server" {
 # db
 resource "aws_s3_bucket" "chadedwardsapi" {
   bucket = "chadedwardsapi"
+
+  # used proof of work nonces only matter until their challenge expires
+  lifecycle_rule {
+    id      = "challenges"
+    enabled = true
+    prefix  = "challenges/"
+
+    expiration {
+      days = 1
+    }
+  }
+
+  # presigned upload records that were never completed
+  lifecycle_rule {
+    id      = "uploads"
+    enabled = true
+    prefix  = "uploads/"
+
+    expiration {
+      days = 7
+    }
+  }
 }
 
 resource "aws_s3_bucket_policy" "chadedwardsapi_s3" {
@@ -277,6 +370,62 @@ data "aws_iam_policy_document" "allow_lambda_s3" {
   }
 }
 
+# photos; the images and thumbnails buckets predate this config
+resource "aws_s3_bucket" "renditions" {
+  bucket = "chadedwardsbandrenditions"
+  acl    = "private" # objects are uploaded public-read
+}
+
+resource "aws_s3_bucket" "originals" {
+  bucket = "chadedwardsbandoriginals"
+  acl    = "private"
+}
+
+resource "aws_s3_bucket_public_access_block" "originals" {
+  bucket                  = aws_s3_bucket.originals.id
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}
+
+resource "aws_s3_bucket" "uploads" {
+  bucket = "chadedwardsbanduploads"
+  acl    = "private"
+
+  # browsers PUT photos straight to presigned URLs
+  cors_rule {
+    allowed_methods = ["PUT"]
+    allowed_origins = [
+      "https://chadedwardsband.com",
+      "https://www.chadedwardsband.com",
+      "http://localhost:3000",
+      "http://localhost:3001",
+    ]
+    allowed_headers = ["*"]
+    max_age_seconds = 3000
+  }
+
+  # uploads are deleted once processed; these were never completed or their job failed
+  lifecycle_rule {
+    id      = "abandoned"
+    enabled = true
+
+    expiration {
+      days = 7
+    }
+    abort_incomplete_multipart_upload_days = 1
+  }
+}
+
+resource "aws_s3_bucket_public_access_block" "uploads" {
+  bucket                  = aws_s3_bucket.uploads.id
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}
+
 data "aws_ssm_parameter" "twilio_user" {
   name            = var.twilio_user
   with_decryption = true
@@ -342,6 +491,21 @@ data "aws_ssm_parameter" "positionstack_key" {
   with_decryption = false
 }
 
+data "aws_ssm_parameter" "owner_emails" {
+  name            = var.owner_emails
+  with_decryption = false
+}
+
+data "aws_ssm_parameter" "admin_emails" {
+  name            = var.admin_emails
+  with_decryption = false
+}
+
+data "aws_ssm_parameter" "moderation_words" {
+  name            = var.moderation_words
+  with_decryption = true
+}
+
 # backend
 terraform {
   backend "s3" {
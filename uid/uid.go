@@ -0,0 +1,24 @@
+// Package uid generates the random ids of photos, albums, jobs and song requests.
+package uid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+const size = 8 // bytes
+
+// New returns a random id of 16 hex characters.
+func New() (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Valid reports whether id has the form returned by New.
+func Valid(id string) bool {
+	b, err := hex.DecodeString(id)
+	return err == nil && len(b) == size
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/stinkyfingers/chadedwardsapi/jobs"
+	"github.com/stinkyfingers/chadedwardsapi/server"
+)
+
+/*
+Processes photo jobs queued by the upload endpoints.
+As a Lambda, it is invoked asynchronously with a jobs.Event.
+Locally, run it with -job to (re)process a single job, e.g. one left running by a timed-out Lambda.
+*/
+
+func main() {
+	profile := flag.String("profile", "jds", "aws profile, when run locally")
+	jobID := flag.String("job", "", "id of the job to run, when run locally")
+	flag.Parse()
+
+	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+		s, err := server.NewServer("")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		lambda.Start(func(ctx context.Context, ev jobs.Event) error {
+			return s.RunJob(ctx, ev.JobID)
+		})
+		return
+	}
+
+	if *jobID == "" {
+		log.Fatal("-job is required")
+	}
+	s, err := server.NewServer(*profile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err = s.RunJob(context.Background(), *jobID); err != nil {
+		log.Fatal(err)
+	}
+}